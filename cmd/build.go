@@ -2,36 +2,41 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"forklift/internal/build"
-	"forklift/internal/config"
 	"forklift/internal/git"
-	"forklift/internal/sheets"
+	"forklift/internal/settings"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	stealLock     bool
+	buildWorktree bool
+	buildBump     string
+	buildPre      string
+	buildTagType  string
+	buildNotes    string
+	buildRelease  bool
+	buildAssets   []string
+	buildStrategy string
+	buildNoHooks  bool
+	buildNewSeq   bool
+)
+
 var buildCmd = &cobra.Command{
-	Use:   "build [merge]",
+	Use:   "build",
+	Short: "Merge, tag and push builds",
+}
+
+var buildMergeCmd = &cobra.Command{
+	Use:   "merge",
 	Short: "Build and merge current branch into merge branch",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		if args[0] != "merge" {
-			fatalf("unknown command. did you mean 'build merge'?")
-		}
-
-		cfg, err := config.Load()
-		if err != nil {
-			fatalf("failed to load config: %v", err)
-		}
-		if cfg.SheetID == "" || cfg.CredentialsPath == "" {
-			fatalf("configuration not found. run 'forklift init' first.")
-		}
-
 		ctx := context.Background()
-		service, err := sheets.NewService(ctx, cfg.CredentialsPath)
-		if err != nil {
-			fatalf("failed to initialize Google Sheets client: %v", err)
-		}
+		cfg, store := loadStore(ctx)
 
 		// If resuming, we don't strictly need to detect repo name again as it's in state,
 		// but Run() handles state checks.
@@ -52,12 +57,129 @@ var buildCmd = &cobra.Command{
 			// Actually build.Run gets state path, checks file.
 		}
 
-		if err := build.Run(ctx, service, cfg.SheetID, cfg.SheetName, repoName); err != nil {
+		repoCfg := loadRepoConfig()
+		set := repoSettings(ctx, cfg, store, repoName, repoCfg)
+		if cmd.Flags().Changed("strategy") {
+			set.Strategy.Set(buildStrategy, settings.SourceFlag)
+		}
+		opts := build.Options{
+			StealLock:   stealLock,
+			Worktree:    set.Worktree.Value,
+			TagTemplate: set.TagTemplate.Value,
+			Bump:        buildBump,
+			Pre:         buildPre,
+			NewSequence: buildNewSeq,
+			TagType:     set.TagType.Value,
+			Notes:       set.ReleaseNotes.Value,
+			Strategy:    set.Strategy.Value,
+			NoHooks:     buildNoHooks,
+			RepoConfig:  repoCfg,
+		}
+		if cmd.Flags().Changed("notes") {
+			opts.Notes = buildNotes
+		}
+		if cmd.Flags().Changed("tag-type") {
+			opts.TagType = buildTagType
+		}
+		if cmd.Flags().Changed("worktree") {
+			opts.Worktree = buildWorktree
+		}
+		release := set.GitHubRelease.Value
+		if cmd.Flags().Changed("release") {
+			release = buildRelease
+		}
+		if release || len(buildAssets) > 0 {
+			opts.Release = &build.ReleaseOptions{
+				Token:        cfg.GitHubToken,
+				APIURL:       cfg.GitHubAPIURL,
+				Assets:       set.ReleaseAssets.Value,
+				ProdBranches: set.ProdBranches.Value,
+			}
+			if len(buildAssets) > 0 {
+				opts.Release.Assets = buildAssets
+			}
+		}
+
+		if err := build.Run(ctx, store, repoName, opts); err != nil {
 			fatalf("build failed: %v", err)
 		}
 	},
 }
 
+var buildStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the build in progress, if any",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := build.GetStatus()
+		if err != nil {
+			fatalf("failed to get build status: %v", err)
+		}
+
+		if status.State == nil {
+			fmt.Println("No build in progress.")
+		} else {
+			state := status.State
+			fmt.Println("⏸️  Build in progress:")
+			fmt.Printf("  Repo:            %s\n", state.RepoName)
+			fmt.Printf("  Merging:         %s → %s\n", state.OriginalBranch, state.MergeBranch)
+			fmt.Printf("  Strategy:        %s\n", orDash(state.Strategy))
+			if state.WorktreePath != "" {
+				fmt.Printf("  Worktree:        %s\n", state.WorktreePath)
+			}
+			fmt.Printf("  Stashed changes: %s\n", yesNo(state.Stashed))
+			fmt.Printf("  Tag:             %s\n", orDash(state.Tag))
+			fmt.Printf("  Completed steps: %s\n", orDash(strings.Join(state.Steps, ", ")))
+			fmt.Printf("  Next step:       %s\n", orDash(build.NextStep(*state)))
+		}
+		fmt.Printf("  Current branch:  %s\n", orDash(status.CurrentBranch))
+		fmt.Printf("  Merge conflicts: %s\n", yesNo(status.MergeInProgress))
+		fmt.Printf("  Forklift stash:  %s\n", orDash(status.StashRef))
+
+		if status.State != nil {
+			if status.MergeInProgress {
+				fmt.Printf("\n%s, then run 'forklift build merge' to finish, or 'forklift build abort' to give up.\n", build.ConflictHelp(status.State.Strategy))
+			} else {
+				fmt.Println("\nRun 'forklift build merge' to finish, or 'forklift build abort' to give up.")
+			}
+		}
+	},
+}
+
+var buildAbortCmd = &cobra.Command{
+	Use:   "abort",
+	Short: "Abort the build in progress and restore your branch and changes",
+	Long: `Abort the merge of a conflicted or interrupted build, reset the merge
+branch to where it was if the merge wasn't pushed yet, switch back to the
+branch you started from, pop the stash forklift created (other stashes are left
+alone), remove the build worktree if any, and delete the saved build state.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := build.Abort(); err != nil {
+			fatalf("build abort failed: %v", err)
+		}
+	},
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
 func init() {
+	buildMergeCmd.Flags().BoolVar(&stealLock, "steal-lock", false, "Take over the build lock even if another build holds it")
+	buildMergeCmd.Flags().BoolVar(&buildWorktree, "worktree", false, "Merge in a temporary git worktree, leaving your checkout untouched")
+	buildMergeCmd.Flags().StringVar(&buildBump, "bump", "", "Semver part to bump: major, minor or patch (default: the smallest in the tag template)")
+	buildMergeCmd.Flags().StringVar(&buildPre, "pre", "", "Make the new tag a pre-release, e.g. rc or beta")
+	buildMergeCmd.Flags().BoolVar(&buildNewSeq, "new-sequence", false, "Start a new tag sequence if the latest tag doesn't match the tag template")
+	buildMergeCmd.Flags().StringVar(&buildNotes, "notes", "", "Release notes to print after the build: markdown, json or none (default: release_notes from the config, else markdown)")
+	buildMergeCmd.Flags().StringVar(&buildTagType, "tag-type", "", "Tag type: lightweight, annotated or signed (default: tag_type from .forklift.yaml or the config, else lightweight)")
+	buildMergeCmd.Flags().StringVar(&buildStrategy, "strategy", "", "Merge strategy: merge, squash, rebase or ff-only (default: strategy from .forklift.yaml, else the repo's Merge Strategy in the backend, else merge)")
+	buildMergeCmd.Flags().BoolVar(&buildNoHooks, "no-hooks", false, "Skip the repository's hooks")
+	buildMergeCmd.Flags().BoolVar(&buildRelease, "release", false, "Publish a GitHub release for the new tag (default: github_release from the config)")
+	buildMergeCmd.Flags().StringArrayVar(&buildAssets, "asset", nil, "File or glob pattern to attach to the GitHub release; repeatable, implies --release")
+	buildCmd.AddCommand(buildMergeCmd, buildStatusCmd, buildAbortCmd)
 	rootCmd.AddCommand(buildCmd)
 }
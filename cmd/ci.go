@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/config"
+	"forklift/internal/github"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// requeueDelay gives GitHub time to queue re-run or cancelled runs before
+// polling them, so their old status isn't reported.
+const requeueDelay = 5 * time.Second
+
+var (
+	ciFailedOnly bool
+	ciPoll       bool
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Re-run or cancel the GitHub Actions workflows of a tag",
+}
+
+var ciRerunCmd = &cobra.Command{
+	Use:   "rerun [tag-name]",
+	Short: "Re-run the failed workflow runs of a tag",
+	Long: `Re-run the workflow runs of a tag that failed or were cancelled, or with
+--failed-only just their failed jobs. A workflow picked with --workflow is
+re-run even if it succeeded. Without a tag, the latest tag from the sheet is
+used. Pass --poll to poll the runs afterwards.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		client, tag, runs, opts := ciRuns(ctx, cmd, args)
+		picked := cmd.Flags().Changed("workflow")
+
+		rerun := 0
+		for _, run := range runs {
+			switch {
+			case run.Status != "completed":
+				fmt.Printf("⏳ %s is still %s, skipping it\n", run.Name, run.Status)
+				continue
+			case run.Conclusion == "success" && (!picked || ciFailedOnly):
+				fmt.Printf("✔️  %s succeeded, skipping it\n", run.Name)
+				continue
+			}
+
+			var err error
+			if ciFailedOnly {
+				fmt.Printf("🔁 Re-running the failed jobs of %s...\n", run.Name)
+				err = client.RerunFailedJobs(ctx, run.RunID)
+			} else {
+				fmt.Printf("🔁 Re-running %s...\n", run.Name)
+				err = client.RerunRun(ctx, run.RunID)
+			}
+			if err != nil {
+				fatalf("%v", err)
+			}
+			rerun++
+		}
+
+		if rerun == 0 {
+			fmt.Printf("Nothing to re-run for tag %s.\n", tag)
+			return
+		}
+		if ciPoll {
+			fmt.Println()
+			time.Sleep(requeueDelay)
+			pollTag(ctx, client, tag, opts)
+		}
+	},
+}
+
+var ciCancelCmd = &cobra.Command{
+	Use:   "cancel [tag-name]",
+	Short: "Cancel the queued and running workflow runs of a tag",
+	Long: `Cancel the queued and running workflow runs of a tag. Without a tag, the
+latest tag from the sheet is used. Pass --poll to poll the runs until they
+stopped.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		client, tag, runs, opts := ciRuns(ctx, cmd, args)
+
+		cancelled := 0
+		for _, run := range runs {
+			if run.Status == "completed" {
+				continue
+			}
+			fmt.Printf("🛑 Cancelling %s...\n", run.Name)
+			if err := client.CancelRun(ctx, run.RunID); err != nil {
+				fatalf("%v", err)
+			}
+			cancelled++
+		}
+
+		if cancelled == 0 {
+			fmt.Printf("No queued or running workflow runs for tag %s.\n", tag)
+			return
+		}
+		if ciPoll {
+			fmt.Println()
+			time.Sleep(requeueDelay)
+			pollTag(ctx, client, tag, opts)
+		}
+	},
+}
+
+// ciRuns finds the workflow runs of the tag in args, or of the latest tag,
+// the same way 'poll tag' does.
+func ciRuns(ctx context.Context, cmd *cobra.Command, args []string) (*github.Client, string, []github.WorkflowStatus, pollOptions) {
+	cfg, err := config.Load()
+	if err != nil {
+		fatalf("failed to load config: %v", err)
+	}
+	tag := tagToPoll(ctx, args, false)
+	client := githubClient(cfg)
+	opts := pollOptionsFor(cmd, cfg)
+
+	runs, err := client.WorkflowRunsForTag(ctx, tag, opts.workflow)
+	if err != nil {
+		fatalf("failed to find workflow runs: %v", err)
+	}
+	if len(runs) == 0 {
+		fatalf("no workflow runs found for tag %s", tag)
+	}
+	return client, tag, runs, opts
+}
+
+func init() {
+	ciRerunCmd.Flags().BoolVar(&ciFailedOnly, "failed-only", false, "Only re-run the failed jobs")
+	for _, c := range []*cobra.Command{ciRerunCmd, ciCancelCmd} {
+		c.Flags().BoolVarP(&ciPoll, "poll", "p", false, "Poll the workflow runs afterwards")
+		addPollFlags(c)
+	}
+	ciCmd.AddCommand(ciRerunCmd, ciCancelCmd)
+	rootCmd.AddCommand(ciCmd)
+}
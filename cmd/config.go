@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/git"
+	"forklift/internal/hooks"
+	"forklift/internal/repoconfig"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect forklift settings",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective settings of the current repository",
+	Long: `Show the settings forklift uses in the current repository and where each
+value comes from. Sources are merged in this order, later ones winning:
+defaults, the user config, the repo's row in the backend, the repository's
+` + repoconfig.FileName + ` and command-line flags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		cfg, store := loadStore(ctx)
+		repoName, err := git.DetectRepoName()
+		if err != nil {
+			fmt.Printf("⚠️  Not in a repository with a GitHub remote, showing user settings only: %v\n\n", err)
+		}
+		resolved := repoSettings(ctx, cfg, store, repoName, loadRepoConfig())
+		if repoName != "" {
+			fmt.Printf("⚙️  Settings of %s:\n\n", repoName)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+		for _, e := range resolved.Entries() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, orDash(e.Value), orDash(string(e.Source)))
+		}
+		w.Flush()
+
+		root, err := git.TopLevel()
+		if err != nil {
+			return
+		}
+		set, err := hooks.Load(root)
+		if err != nil {
+			fatalf("failed to load hooks: %v", err)
+		}
+		fmt.Println("\nHooks:")
+		for _, phase := range hooks.Phases {
+			for _, h := range set[phase] {
+				fmt.Printf("  %-11s %s\n", phase, h.Name)
+			}
+		}
+		if len(set) == 0 {
+			fmt.Println("  none")
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
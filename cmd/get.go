@@ -4,9 +4,7 @@ import (
 	"context"
 	"fmt"
 	"forklift/internal/clipboard"
-	"forklift/internal/config"
 	"forklift/internal/git"
-	"forklift/internal/sheets"
 	"forklift/internal/structures"
 
 	"github.com/spf13/cobra"
@@ -58,26 +56,15 @@ var getTagCmd = &cobra.Command{
 }
 
 func fetchRepoInfo() *structures.RepoInfo {
-	cfg, err := config.Load()
-	if err != nil {
-		fatalf("failed to load config: %v", err)
-	}
-	if cfg.SheetID == "" || cfg.CredentialsPath == "" {
-		fatalf("configuration not found. run 'forklift init' first.")
-	}
-
 	ctx := context.Background()
-	service, err := sheets.NewService(ctx, cfg.CredentialsPath)
-	if err != nil {
-		fatalf("failed to initialize Google Sheets client: %v", err)
-	}
+	_, store := loadStore(ctx)
 
 	repoName, err := git.DetectRepoName()
 	if err != nil {
 		fatalf("failed to detect repo name: %v", err)
 	}
 
-	info, err := service.GetRepoInfo(ctx, cfg.SheetID, cfg.SheetName, repoName)
+	info, err := store.GetRepoInfo(ctx, repoName)
 	if err != nil {
 		fatalf("failed to read repo info: %v", err)
 	}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/git"
+	"forklift/internal/structures"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyBranch  string
+	historyUser    string
+	historyOutcome string
+	historyLimit   int
+	historyPage    int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the tag history of the repository",
+	Long:  `List every build recorded for the current repository, newest first.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if historyLimit <= 0 {
+			fatalf("--limit must be positive")
+		}
+		if historyPage <= 0 {
+			fatalf("--page must be positive")
+		}
+
+		ctx := context.Background()
+		_, store := loadStore(ctx)
+
+		repoName, err := git.DetectRepoName()
+		if err != nil {
+			fatalf("failed to detect repo name: %v", err)
+		}
+
+		records, err := store.ListHistory(ctx, repoName)
+		if err != nil {
+			fatalf("failed to read history: %v", err)
+		}
+
+		// Newest first
+		var matched []structures.HistoryRecord
+		for i := len(records) - 1; i >= 0; i-- {
+			if matchesHistoryFilters(records[i]) {
+				matched = append(matched, records[i])
+			}
+		}
+
+		if len(matched) == 0 {
+			fmt.Println("No history found.")
+			return
+		}
+
+		pages := (len(matched) + historyLimit - 1) / historyLimit
+		if historyPage > pages {
+			fatalf("page %d out of range (%d pages)", historyPage, pages)
+		}
+		start := (historyPage - 1) * historyLimit
+		end := min(start+historyLimit, len(matched))
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tTAG\tMERGE BRANCH\tSOURCE BRANCH\tCOMMIT\tUSER\tOUTCOME")
+		for _, r := range matched[start:end] {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Timestamp, orDash(r.Tag), r.MergeBranch, r.SourceBranch, shortSHA(r.CommitSHA), r.User, r.Outcome)
+		}
+		w.Flush()
+
+		fmt.Printf("\nPage %d of %d (%d builds)\n", historyPage, pages, len(matched))
+	},
+}
+
+func matchesHistoryFilters(r structures.HistoryRecord) bool {
+	if historyBranch != "" && r.MergeBranch != historyBranch && r.SourceBranch != historyBranch {
+		return false
+	}
+	if historyUser != "" && !strings.Contains(strings.ToLower(r.User), strings.ToLower(historyUser)) {
+		return false
+	}
+	if historyOutcome != "" && r.Outcome != historyOutcome {
+		return false
+	}
+	return true
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return orDash(sha)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyBranch, "branch", "b", "", "Only show builds from or into this branch")
+	historyCmd.Flags().StringVarP(&historyUser, "user", "u", "", "Only show builds by users matching this text")
+	historyCmd.Flags().StringVarP(&historyOutcome, "outcome", "o", "", "Only show builds with this outcome (success, failed, conflict)")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of builds per page")
+	historyCmd.Flags().IntVarP(&historyPage, "page", "p", 1, "Page to show, starting at 1")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -3,10 +3,9 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"forklift/internal/backend"
 	"forklift/internal/config"
 	"forklift/internal/sheets"
-	"forklift/internal/structures"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -16,12 +15,15 @@ import (
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize forklift configuration",
-	Long:  `Set up the Google Sheet ID and credentials path for forklift.`,
+	Long:  `Set up the state backend (Google Sheet or local state file) for forklift.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		reader := bufio.NewReader(os.Stdin)
+		reader := bufio.NewReader(cmd.InOrStdin())
 
-		// Load existing config
-		currentCfg, _ := config.Load()
+		// Load existing config; init only changes the settings it prompts for
+		currentCfg, err := config.Load()
+		if err != nil {
+			fatalf("Failed to load config: %v", err)
+		}
 
 		// Helper to prompt with default
 		prompt := func(label, currentVal string, required bool) string {
@@ -38,39 +40,71 @@ var initCmd = &cobra.Command{
 			return input
 		}
 
-		// 1. Google Sheet URL/ID
-		sheetInput := prompt("Enter Google Sheet URL or ID", currentCfg.SheetID, true)
-		if sheetInput == "" && currentCfg.SheetID == "" {
-			fatalf("Sheet ID is required")
+		// 1. Backend
+		defaultBackend := currentCfg.Backend
+		if defaultBackend == "" {
+			defaultBackend = backend.Sheets
 		}
-		sheetID, err := sheets.ExtractSheetID(sheetInput)
-		if err != nil {
-			// If extraction fails, assume it's already an ID if no change
-			if sheetInput == currentCfg.SheetID {
-				sheetID = sheetInput
-			} else {
-				fatalf("Invalid Sheet URL/ID: %v", err)
-			}
+		backendName := prompt(fmt.Sprintf("Enter state backend (%s or %s)", backend.Sheets, backend.File), defaultBackend, false)
+		if backendName != backend.Sheets && backendName != backend.File {
+			fatalf("Invalid backend: %s", backendName)
 		}
 
-		// 2. Credentials Path
-		credPath := prompt("Enter path to credentials.json", currentCfg.CredentialsPath, true)
-		if credPath == "" {
-			fatalf("Credentials path is required")
-		}
-		absPath, err := filepath.Abs(credPath)
-		if err != nil {
-			fatalf("Invalid path: %v", err)
-		}
+		// Keep the settings of the backend that is not selected, so switching back is easy
+		sheetID, absPath, sheetName, statePath := currentCfg.SheetID, currentCfg.CredentialsPath, currentCfg.SheetName, currentCfg.StatePath
+		if backendName == backend.File {
+			// 2. State file path
+			defaultStatePath := currentCfg.StatePath
+			if defaultStatePath == "" {
+				if cfgPath, err := config.Path(); err == nil {
+					defaultStatePath = filepath.Join(filepath.Dir(cfgPath), "state.json")
+				}
+			}
+			stateInput := prompt("Enter path to the state file (may be on a shared filesystem)", defaultStatePath, true)
+			if stateInput == "" {
+				fatalf("State file path is required")
+			}
+			var err error
+			statePath, err = filepath.Abs(stateInput)
+			if err != nil {
+				fatalf("Invalid path: %v", err)
+			}
+		} else {
+			// 2. Google Sheet URL/ID
+			sheetInput := prompt("Enter Google Sheet URL or ID", currentCfg.SheetID, true)
+			if sheetInput == "" && currentCfg.SheetID == "" {
+				fatalf("Sheet ID is required")
+			}
+			var err error
+			sheetID, err = sheets.ExtractSheetID(sheetInput)
+			if err != nil {
+				// If extraction fails, assume it's already an ID if no change
+				if sheetInput == currentCfg.SheetID {
+					sheetID = sheetInput
+				} else {
+					fatalf("Invalid Sheet URL/ID: %v", err)
+				}
+			}
 
-		// 3. Sheet Name
-		defaultSheetName := currentCfg.SheetName
-		if defaultSheetName == "" {
-			defaultSheetName = config.DefaultSheetName
-		}
-		sheetName := prompt("Enter Sheet Name", defaultSheetName, false)
-		if sheetName == "" {
-			sheetName = defaultSheetName
+			// 3. Credentials Path
+			credPath := prompt("Enter path to credentials.json", currentCfg.CredentialsPath, true)
+			if credPath == "" {
+				fatalf("Credentials path is required")
+			}
+			absPath, err = filepath.Abs(credPath)
+			if err != nil {
+				fatalf("Invalid path: %v", err)
+			}
+
+			// 4. Sheet Name
+			defaultSheetName := currentCfg.SheetName
+			if defaultSheetName == "" {
+				defaultSheetName = config.DefaultSheetName
+			}
+			sheetName = prompt("Enter Sheet Name", defaultSheetName, false)
+			if sheetName == "" {
+				sheetName = defaultSheetName
+			}
 		}
 
 		// GitHub token
@@ -105,20 +139,24 @@ var initCmd = &cobra.Command{
 			}
 		}
 
-		cfg := structures.Config{
-			SheetID:         sheetID,
-			SheetName:       sheetName,
-			CredentialsPath: absPath,
-			GitHubToken:     githubToken,
-			PollInterval:    pollInterval,
-			PollTimeout:     pollTimeout,
-		}
+		cfg := currentCfg
+		cfg.Backend = backendName
+		cfg.StatePath = statePath
+		cfg.SheetID = sheetID
+		cfg.SheetName = sheetName
+		cfg.CredentialsPath = absPath
+		cfg.GitHubToken = githubToken
+		cfg.PollInterval = pollInterval
+		cfg.PollTimeout = pollTimeout
 
 		if err := config.Save(cfg); err != nil {
 			fatalf("Failed to save config: %v", err)
 		}
 
 		fmt.Println("🏗️  Configuration saved successfully! You're ready to roll.")
+		if backendName == backend.Sheets {
+			fmt.Println("   Run 'forklift sheet setup' to create the tabs forklift needs.")
+		}
 	},
 }
 
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"forklift/internal/config"
+	"forklift/internal/structures"
+)
+
+func TestInitKeepsOtherSettings(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	existing := structures.Config{
+		Backend:         "file",
+		StatePath:       filepath.Join(dir, "state.json"),
+		SheetID:         "sheet-id",
+		SheetName:       "merge_branches",
+		HistorySheet:    "history",
+		LockSheet:       "locks",
+		MetaSheet:       "meta",
+		CredentialsPath: "/creds.json",
+		SheetsEndpoint:  "http://localhost:8080/",
+		GitHubToken:     "token",
+		PollInterval:    30,
+		PollTimeout:     30,
+		Worktree:        true,
+		TagTemplate:     "build-{seq}",
+		TagType:         "annotated",
+		ReleaseNotes:    "json",
+		GitHubRelease:   true,
+		ReleaseAssets:   []string{"dist/*"},
+		ProdBranches:    []string{"main"},
+		GitHubAPIURL:    "https://github.example.com/api/v3",
+		NotifyWebhooks:  []string{"https://hooks.example.com/x"},
+	}
+	tests := []struct {
+		name  string
+		input string // answers to the prompts: backend, state file, token, interval, timeout
+		want  func(cfg *structures.Config)
+	}{
+		{
+			name:  "keep everything",
+			input: "\n\n\n\n\n",
+			want:  func(cfg *structures.Config) {},
+		},
+		{
+			name:  "change the poll interval",
+			input: "\n\n\n60\n\n",
+			want:  func(cfg *structures.Config) { cfg.PollInterval = 60 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := config.Save(existing); err != nil {
+				t.Fatal(err)
+			}
+			initCmd.SetIn(strings.NewReader(tt.input))
+			initCmd.Run(initCmd, nil)
+
+			got, err := config.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := existing
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("config after init = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
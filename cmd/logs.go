@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/config"
+	"forklift/internal/settings"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultLogLines is how many lines of a failed step's log are printed.
+const defaultLogLines = 50
+
+var (
+	logsLines    int
+	logsWorkflow string
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [tag-name]",
+	Short: "Print the logs of the failed jobs of a tag's workflows",
+	Long: `Download the logs of the workflow runs of a tag and print the end of the
+output of every failed step, with error annotations highlighted. Without a tag,
+the latest tag from the sheet is used.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		cfg, err := config.Load()
+		if err != nil {
+			fatalf("failed to load config: %v", err)
+		}
+
+		tag := tagToPoll(ctx, args, false)
+		client := githubClient(cfg)
+
+		set := settings.Resolve(settings.Layers{User: cfg, Repo: loadRepoConfig()})
+		if cmd.Flags().Changed("workflow") {
+			set.Workflow.Set(logsWorkflow, settings.SourceFlag)
+		}
+
+		runs, err := client.WorkflowRunsForTag(ctx, tag, set.Workflow.Value)
+		if err != nil {
+			fatalf("failed to find workflow runs: %v", err)
+		}
+		if len(runs) == 0 {
+			fatalf("no workflow runs found for tag %s", tag)
+		}
+
+		failed := 0
+		for _, run := range runs {
+			switch {
+			case run.Status != "completed":
+				fmt.Printf("⏳ %s is still %s, its logs are available once it completes\n", run.Name, run.Status)
+			case run.Conclusion == "failure" || run.Conclusion == "timed_out":
+				fmt.Printf("❌ %s failed\n", run.Name)
+				printFailedJobs(ctx, client, run, logsLines)
+				failed++
+			default:
+				fmt.Printf("✔️  %s: completed (%s)\n", run.Name, run.Conclusion)
+			}
+		}
+		if failed == 0 {
+			fmt.Printf("\nNo failed workflow runs for tag %s.\n", tag)
+		}
+	},
+}
+
+// printLogTail prints the last n lines of a step log. Error annotations
+// before those lines are printed first, so the cause isn't cut off.
+func printLogTail(lines []string, n int) {
+	var out []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "##[endgroup]") {
+			continue
+		}
+		out = append(out, strings.TrimPrefix(line, "##[group]"))
+	}
+
+	start := max(len(out)-n, 0)
+	var earlier []string
+	for _, line := range out[:start] {
+		if strings.HasPrefix(line, "##[error]") {
+			earlier = append(earlier, line)
+		}
+	}
+	if len(earlier) > 0 {
+		fmt.Println("      Earlier errors:")
+		for _, line := range earlier {
+			fmt.Println(highlightLogLine(line))
+		}
+		fmt.Println("      ...")
+	}
+	for _, line := range out[start:] {
+		fmt.Println(highlightLogLine(line))
+	}
+	fmt.Println()
+}
+
+// highlightLogLine indents a log line and marks ##[error] and ##[warning]
+// annotations, in color on a terminal.
+func highlightLogLine(line string) string {
+	const indent = "      │ "
+	if msg, ok := strings.CutPrefix(line, "##[error]"); ok {
+		return indent + colorize("31;1", "❌ "+msg)
+	}
+	if msg, ok := strings.CutPrefix(line, "##[warning]"); ok {
+		return indent + colorize("33", "⚠️  "+msg)
+	}
+	return indent + line
+}
+
+// colorize wraps s in an ANSI color if stdout is a terminal and NO_COLOR is
+// not set.
+func colorize(code, s string) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	if fi, err := os.Stdout.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
+
+func init() {
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", defaultLogLines, "Number of log lines to print per failed step")
+	logsCmd.Flags().StringVarP(&logsWorkflow, "workflow", "w", "", "Only show the workflow with this name or file name (default: workflow from .forklift.yaml, else any)")
+	rootCmd.AddCommand(logsCmd)
+}
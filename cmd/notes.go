@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/build"
+	"forklift/internal/git"
+	"forklift/internal/notes"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	notesFormat string
+	notesFrom   string
+)
+
+var notesCmd = &cobra.Command{
+	Use:   "notes <tag>",
+	Short: "Generate release notes for a tag",
+	Long: `Generate release notes from the commits between a tag and the tag built before
+it on the same merge branch, grouped by Conventional Commit type and listing the
+pull requests found in commit subjects. The previous tag is looked up in the tag
+history, falling back to the closest earlier tag in git; use --from to pick it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tag := args[0]
+		ctx := context.Background()
+
+		prev := notesFrom
+		if !cmd.Flags().Changed("from") {
+			_, store := loadStore(ctx)
+			repoName, err := git.DetectRepoName()
+			if err != nil {
+				fatalf("failed to detect repo name: %v", err)
+			}
+			if prev, err = build.PreviousTag(ctx, store, repoName, tag); err != nil {
+				fatalf("%v", err)
+			}
+		}
+
+		n, err := build.ReleaseNotes(git.Default, tag, prev)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		out, err := n.Render(notesFormat)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Print(out)
+	},
+}
+
+func init() {
+	notesCmd.Flags().StringVarP(&notesFormat, "format", "f", notes.Markdown, "Output format: markdown or json")
+	notesCmd.Flags().StringVar(&notesFrom, "from", "", "Previous tag to start from (default: looked up in the tag history)")
+	rootCmd.AddCommand(notesCmd)
+}
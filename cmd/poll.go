@@ -7,8 +7,11 @@ import (
 	"forklift/internal/git"
 	"forklift/internal/github"
 	"forklift/internal/notification"
-	"forklift/internal/sheets"
+	"forklift/internal/settings"
+	"forklift/internal/structures"
+	"os"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -19,6 +22,9 @@ var (
 	pollTimeout  int
 	noNotify     bool
 	pollLatest   bool
+	pollWorkflow string
+	pollLogs     bool
+	pollLogLines int
 )
 
 var pollCmd = &cobra.Command{
@@ -29,151 +35,319 @@ var pollCmd = &cobra.Command{
 var pollTagCmd = &cobra.Command{
 	Use:   "tag [tag-name]",
 	Short: "Poll GitHub Actions workflow status for a tag",
-	Long:  `Monitor the GitHub Actions workflow run for a specific tag and get notified when it completes.`,
+	Long:  `Monitor the GitHub Actions workflow runs for a specific tag and get notified when they complete.`,
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
 		cfg, err := config.Load()
 		if err != nil {
 			fatalf("failed to load config: %v", err)
 		}
 
-		// Get tag to poll
-		var tag string
-		if len(args) == 1 {
-			tag = args[0]
-		}
-
-		if pollLatest || tag == "" {
-			// Poll the latest tag from the sheet
-			ctx := context.Background()
-			service, err := sheets.NewService(ctx, cfg.CredentialsPath)
-			if err != nil {
-				fatalf("failed to initialize Google Sheets client: %v", err)
-			}
+		tag := tagToPoll(ctx, args, pollLatest)
+		client := githubClient(cfg)
+		pollTag(ctx, client, tag, pollOptionsFor(cmd, cfg))
+	},
+}
 
-			repoName, err := git.DetectRepoName()
-			if err != nil {
-				fatalf("failed to detect repo name: %v", err)
-			}
+// pollOptions control how pollTag polls.
+type pollOptions struct {
+	workflow string // empty polls every workflow of the tag
+	interval time.Duration
+	timeout  time.Duration
+	targets  notification.Targets
+	logLines int // log lines to print per failed step, 0 for none
+}
 
-			info, err := service.GetRepoInfo(ctx, cfg.SheetID, cfg.SheetName, repoName)
-			if err != nil {
-				fatalf("failed to read repo info: %v", err)
-			}
+// addPollFlags adds the flags read by pollOptionsFor to cmd.
+func addPollFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&pollInterval, "interval", "i", 0, "Polling interval in seconds (default: 30)")
+	cmd.Flags().IntVarP(&pollTimeout, "timeout", "t", 0, "Timeout in minutes (default: 30)")
+	cmd.Flags().BoolVar(&noNotify, "no-notify", false, "Disable notifications")
+	cmd.Flags().StringVarP(&pollWorkflow, "workflow", "w", "", "Only consider the workflow with this name or file name (default: workflow from .forklift.yaml, else any)")
+	cmd.Flags().BoolVar(&pollLogs, "logs", false, "Print the end of the log of each failed step")
+	cmd.Flags().IntVarP(&pollLogLines, "lines", "n", defaultLogLines, "Number of log lines to print per failed step with --logs")
+}
 
-			if info == nil || info.LatestTag == "" {
-				fatalf("no tag found in sheet for %s", repoName)
-			}
+// pollOptionsFor resolves the poll options of cmd. Flags override the repo
+// and user config.
+func pollOptionsFor(cmd *cobra.Command, cfg structures.Config) pollOptions {
+	set := settings.Resolve(settings.Layers{User: cfg, Repo: loadRepoConfig()})
+	if pollInterval > 0 {
+		set.PollInterval.Set(pollInterval, settings.SourceFlag)
+	}
+	if pollTimeout > 0 {
+		set.PollTimeout.Set(pollTimeout, settings.SourceFlag)
+	}
+	if cmd.Flags().Changed("workflow") {
+		set.Workflow.Set(pollWorkflow, settings.SourceFlag)
+	}
+	if noNotify {
+		set.NotifyDesktop.Set(false, settings.SourceFlag)
+		set.NotifyWebhooks.Set(nil, settings.SourceFlag)
+	}
+	o := pollOptions{
+		workflow: set.Workflow.Value,
+		interval: time.Duration(set.PollInterval.Value) * time.Second,
+		timeout:  time.Duration(set.PollTimeout.Value) * time.Minute,
+		targets:  notification.Targets{Desktop: set.NotifyDesktop.Value, Webhooks: set.NotifyWebhooks.Value},
+	}
+	if pollLogs {
+		o.logLines = pollLogLines
+	}
+	return o
+}
 
-			tag = info.LatestTag
-			fmt.Printf("📋 Using latest tag from sheet: %s\n", tag)
-		}
+// pollTag polls the workflow runs of tag until all of them completed, then
+// reports them.
+func pollTag(ctx context.Context, client *github.Client, tag string, o pollOptions) {
+	if o.workflow != "" {
+		fmt.Printf("🏷️  Polling workflow %s for tag %s...\n", o.workflow, tag)
+	} else {
+		fmt.Printf("🏷️  Polling workflow for tag %s...\n", tag)
+	}
+	fmt.Printf("⏱️  Interval: %ds | Timeout: %dm\n\n", int(o.interval.Seconds()), int(o.timeout.Minutes()))
 
-		// Parse org/repo from git remote
-		repoName, err := git.DetectRepoName()
+	startTime := time.Now()
+	for {
+		requests := client.Requests()
+		runs, err := client.WorkflowRunsForTag(ctx, tag, o.workflow)
 		if err != nil {
-			fatalf("failed to detect repo name: %v", err)
+			fmt.Printf("⚠️  %v\n", err)
 		}
+		if len(runs) == 0 {
+			// Workflow might not have started yet
+			elapsed := time.Since(startTime)
+			fmt.Printf("⏳ Waiting for workflow to start... (%s elapsed)\n", formatDuration(elapsed))
 
-		parts := strings.Split(repoName, "/")
-		if len(parts) != 2 {
-			fatalf("invalid repo format: %s (expected org/repo)", repoName)
-		}
-		owner, repo := parts[0], parts[1]
+			if elapsed > o.timeout {
+				fmt.Println("\n⏰ Timeout reached. No workflow found.")
+				return
+			}
 
-		// Check if GitHub token is configured
-		if cfg.GitHubToken == "" {
-			fmt.Println("⚠️  No GitHub token configured. API rate limits will be very restrictive.")
-			fmt.Println("   Run 'forklift init' to add your GitHub token.")
+			pollWait(client, o.interval, client.Requests()-requests)
+			continue
 		}
 
-		// Use config defaults or flags
-		interval := pollInterval
-		if interval == 0 {
-			if cfg.PollInterval > 0 {
-				interval = cfg.PollInterval
-			} else {
-				interval = 30 // default
+		if allCompleted(runs) {
+			fmt.Printf("\n")
+			reportRuns(ctx, client, tag, runs, o.targets, o.logLines)
+			return
+		}
+		for _, run := range runs {
+			switch run.Status {
+			case "completed":
+				fmt.Printf("✔️  %s: completed (%s)\n", run.Name, run.Conclusion)
+			case "in_progress":
+				fmt.Printf("⏳ %s: in_progress (running for %s)\n", run.Name, formatDuration(time.Since(run.CreatedAt)))
+			default:
+				fmt.Printf("⏳ %s: %s (waiting to start)\n", run.Name, run.Status)
+			}
+			if run.Status != "completed" {
+				printJobs(ctx, client, run)
 			}
 		}
 
-		timeout := pollTimeout
-		if timeout == 0 {
-			if cfg.PollTimeout > 0 {
-				timeout = cfg.PollTimeout
-			} else {
-				timeout = 30 // default (minutes)
-			}
+		if time.Since(startTime) > o.timeout {
+			fmt.Println("\n⏰ Timeout reached.")
+			return
 		}
 
-		// Create GitHub client
-		client := github.NewClient(cfg.GitHubToken, owner, repo)
+		pollWait(client, o.interval, client.Requests()-requests)
+	}
+}
 
-		fmt.Printf("🏷️  Polling workflow for tag %s...\n", tag)
-		fmt.Printf("⏱️  Interval: %ds | Timeout: %dm\n\n", interval, timeout)
+// pollWait sleeps until the next poll: interval, or longer if polling that
+// often with perPoll requests each would use up the GitHub API quota before
+// it resets.
+func pollWait(client *github.Client, interval time.Duration, perPoll int) {
+	limit := client.RateLimit()
+	wait := limit.PollInterval(interval, perPoll, time.Now())
+	if wait > interval {
+		fmt.Printf("🐢 GitHub API quota is low (%d of %d requests left until %s), next check in %s\n",
+			limit.Remaining, limit.Limit, limit.Reset.Format("15:04"), formatDuration(wait))
+	}
+	time.Sleep(wait)
+}
 
-		startTime := time.Now()
-		ticker := time.NewTicker(time.Duration(interval) * time.Second)
-		defer ticker.Stop()
+// tagToPoll returns the tag given in args, or the latest tag of the repo in
+// the backend if there is none or latest is set.
+func tagToPoll(ctx context.Context, args []string, latest bool) string {
+	if len(args) == 1 && !latest {
+		return args[0]
+	}
+	_, store := loadStore(ctx)
+
+	repoName, err := git.DetectRepoName()
+	if err != nil {
+		fatalf("failed to detect repo name: %v", err)
+	}
 
-		timeoutDuration := time.Duration(timeout) * time.Minute
+	info, err := store.GetRepoInfo(ctx, repoName)
+	if err != nil {
+		fatalf("failed to read repo info: %v", err)
+	}
 
-		for {
-			status, err := client.CheckWorkflowStatusForTag(tag)
-			if err != nil {
-				// Workflow might not have started yet
-				elapsed := time.Since(startTime)
-				fmt.Printf("⏳ Waiting for workflow to start... (%s elapsed)\n", formatDuration(elapsed))
+	if info == nil || info.LatestTag == "" {
+		fatalf("no tag found in sheet for %s", repoName)
+	}
 
-				if elapsed > timeoutDuration {
-					fmt.Println("\n⏰ Timeout reached. No workflow found.")
-					return
-				}
+	fmt.Printf("📋 Using latest tag from sheet: %s\n", info.LatestTag)
+	return info.LatestTag
+}
 
-				<-ticker.C
-				continue
-			}
+// githubClient returns a GitHub client for the repo of the git remote.
+func githubClient(cfg structures.Config) *github.Client {
+	// Parse org/repo from git remote
+	repoName, err := git.DetectRepoName()
+	if err != nil {
+		fatalf("failed to detect repo name: %v", err)
+	}
 
-			elapsed := time.Since(status.CreatedAt)
+	parts := strings.Split(repoName, "/")
+	if len(parts) != 2 {
+		fatalf("invalid repo format: %s (expected org/repo)", repoName)
+	}
+	owner, repo := parts[0], parts[1]
 
-			switch status.Status {
-			case "queued":
-				fmt.Printf("⏳ Status: queued (waiting to start)\n")
-			case "in_progress":
-				fmt.Printf("⏳ Status: in_progress (running for %s)\n", formatDuration(elapsed))
-			case "completed":
-				fmt.Printf("\n")
-				switch status.Conclusion {
-				case "success":
-					fmt.Println("✅ Workflow completed successfully! 🎉")
-					if !noNotify {
-						notification.Send("Forklift Build Complete", fmt.Sprintf("Tag %s built successfully!", tag))
-					}
-				case "failure":
-					fmt.Println("❌ Workflow failed.")
-					if !noNotify {
-						notification.Send("Forklift Build Failed", fmt.Sprintf("Tag %s build failed.", tag))
-					}
-				case "cancelled":
-					fmt.Println("⚠️  Workflow was cancelled.")
-					if !noNotify {
-						notification.Send("Forklift Build Cancelled", fmt.Sprintf("Tag %s build was cancelled.", tag))
-					}
-				default:
-					fmt.Printf("⚠️  Workflow completed with status: %s\n", status.Conclusion)
-				}
-				fmt.Printf("🔗 %s\n", status.HTMLURL)
-				return
-			}
+	// Check if GitHub token is configured
+	if cfg.GitHubToken == "" {
+		fmt.Println("⚠️  No GitHub token configured. API rate limits will be very restrictive.")
+		fmt.Println("   Run 'forklift init' to add your GitHub token.")
+	}
 
-			if time.Since(startTime) > timeoutDuration {
-				fmt.Println("\n⏰ Timeout reached.")
-				return
+	return github.NewClient(cfg.GitHubToken, owner, repo).WithBaseURL(cfg.GitHubAPIURL)
+}
+
+func allCompleted(runs []github.WorkflowStatus) bool {
+	for _, run := range runs {
+		if run.Status != "completed" {
+			return false
+		}
+	}
+	return true
+}
+
+// printJobs prints the status and duration of each job of a run, and the
+// step running jobs are at.
+func printJobs(ctx context.Context, client *github.Client, run github.WorkflowStatus) {
+	jobs, err := client.JobsForRun(ctx, run.RunID)
+	if err != nil {
+		fmt.Printf("   ⚠️  %v\n", err)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, job := range jobs {
+		switch job.Status {
+		case "completed":
+			fmt.Fprintf(w, "   %s %s\t%s\t%s\n", jobIcon(job.Conclusion), job.Name, job.Conclusion, formatDuration(job.Duration()))
+		case "in_progress":
+			fmt.Fprintf(w, "   🔄 %s\t%s\t%s\n", job.Name, currentStep(job), formatDuration(job.Duration()))
+		default:
+			fmt.Fprintf(w, "   ⏳ %s\t%s\n", job.Name, job.Status)
+		}
+	}
+	w.Flush()
+}
+
+func jobIcon(conclusion string) string {
+	switch conclusion {
+	case "success":
+		return "✅"
+	case "failure", "timed_out":
+		return "❌"
+	case "skipped":
+		return "⏩"
+	default:
+		return "🚫"
+	}
+}
+
+// currentStep describes the step a running job is at, e.g. "step 3/8: Test".
+func currentStep(job github.Job) string {
+	for _, step := range job.Steps {
+		if step.Status == "in_progress" {
+			return fmt.Sprintf("step %d/%d: %s", step.Number, len(job.Steps), step.Name)
+		}
+	}
+	return "in_progress"
+}
+
+// printFailedJobs prints the failed jobs of a run and the step each failed at,
+// followed by the last logLines lines of the step's log if logLines > 0.
+func printFailedJobs(ctx context.Context, client *github.Client, run github.WorkflowStatus, logLines int) {
+	jobs, err := client.JobsForRun(ctx, run.RunID)
+	if err != nil {
+		fmt.Printf("   ⚠️  %v\n", err)
+		return
+	}
+	var logs *github.RunLogs
+	for _, job := range jobs {
+		if job.Conclusion != "failure" && job.Conclusion != "timed_out" {
+			continue
+		}
+		step := job.FailedStep()
+		if step != nil {
+			fmt.Printf("   ❌ Job %q failed at step %d %q after %s\n", job.Name, step.Number, step.Name, formatDuration(job.Duration()))
+		} else {
+			fmt.Printf("   ❌ Job %q failed after %s\n", job.Name, formatDuration(job.Duration()))
+			step = &github.Step{} // matches no step, so the whole job log is used
+		}
+		fmt.Printf("      🔗 %s\n", job.HTMLURL)
+
+		if logLines <= 0 {
+			continue
+		}
+		if logs == nil {
+			if logs, err = client.DownloadRunLogs(ctx, run.RunID); err != nil {
+				fmt.Printf("   ⚠️  %v\n", err)
+				logLines = 0
+				continue
 			}
+		}
+		lines, err := logs.StepLog(job, *step)
+		if err != nil {
+			fmt.Printf("   ⚠️  %v\n", err)
+			continue
+		}
+		printLogTail(lines, logLines)
+	}
+}
 
-			<-ticker.C
+// reportRuns prints the conclusion of every finished run, with the failed jobs
+// of failed runs, and sends one notification for the tag: failed if any run
+// failed, else cancelled if any was cancelled, else successful.
+func reportRuns(ctx context.Context, client *github.Client, tag string, runs []github.WorkflowStatus, targets notification.Targets, logLines int) {
+	failed, cancelled := 0, 0
+	for _, run := range runs {
+		switch run.Conclusion {
+		case "success":
+			fmt.Printf("✅ %s completed successfully\n", run.Name)
+		case "failure", "timed_out", "startup_failure":
+			fmt.Printf("❌ %s failed\n", run.Name)
+			printFailedJobs(ctx, client, run, logLines)
+			failed++
+		case "cancelled":
+			fmt.Printf("⚠️  %s was cancelled\n", run.Name)
+			cancelled++
+		default:
+			fmt.Printf("⚠️  %s completed with status: %s\n", run.Name, run.Conclusion)
 		}
-	},
+		fmt.Printf("   🔗 %s\n", run.HTMLURL)
+	}
+
+	fmt.Println()
+	switch {
+	case failed > 0:
+		fmt.Printf("❌ %d of %d workflow(s) failed.\n", failed, len(runs))
+		targets.Notify("Forklift Build Failed", fmt.Sprintf("Tag %s build failed.", tag))
+	case cancelled > 0:
+		fmt.Printf("⚠️  %d of %d workflow(s) were cancelled.\n", cancelled, len(runs))
+		targets.Notify("Forklift Build Cancelled", fmt.Sprintf("Tag %s build was cancelled.", tag))
+	default:
+		fmt.Println("✅ Workflow completed successfully! 🎉")
+		targets.Notify("Forklift Build Complete", fmt.Sprintf("Tag %s built successfully!", tag))
+	}
 }
 
 func formatDuration(d time.Duration) string {
@@ -194,9 +368,7 @@ func formatDuration(d time.Duration) string {
 }
 
 func init() {
-	pollTagCmd.Flags().IntVarP(&pollInterval, "interval", "i", 0, "Polling interval in seconds (default: 30)")
-	pollTagCmd.Flags().IntVarP(&pollTimeout, "timeout", "t", 0, "Timeout in minutes (default: 30)")
-	pollTagCmd.Flags().BoolVar(&noNotify, "no-notify", false, "Disable desktop notifications")
+	addPollFlags(pollTagCmd)
 	pollTagCmd.Flags().BoolVarP(&pollLatest, "latest", "l", false, "Poll the latest tag from the sheet")
 
 	pollCmd.AddCommand(pollTagCmd)
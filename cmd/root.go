@@ -1,7 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"forklift/internal/backend"
+	"forklift/internal/config"
+	"forklift/internal/git"
+	"forklift/internal/repoconfig"
+	"forklift/internal/settings"
+	"forklift/internal/structures"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -30,3 +37,45 @@ func fatalf(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
 	os.Exit(1)
 }
+
+// loadStore loads the user configuration and opens the state backend it selects.
+func loadStore(ctx context.Context) (structures.Config, backend.Store) {
+	cfg, err := config.Load()
+	if err != nil {
+		fatalf("failed to load config: %v", err)
+	}
+	store, err := backend.New(ctx, cfg)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return cfg, store
+}
+
+// loadRepoConfig loads the .forklift.yaml of the current repository. Outside a
+// repository it returns nil, meaning no repo config.
+func loadRepoConfig() *repoconfig.Config {
+	root, err := git.TopLevel()
+	if err != nil {
+		return nil
+	}
+	repoCfg, err := repoconfig.Load(root)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return repoCfg
+}
+
+// repoSettings resolves the effective settings of repoName from the user
+// config, the repo's row in the backend and repoCfg. An empty repoName leaves
+// out the backend.
+func repoSettings(ctx context.Context, cfg structures.Config, store backend.Store, repoName string, repoCfg *repoconfig.Config) *settings.Settings {
+	layers := settings.Layers{User: cfg, Repo: repoCfg}
+	if repoName != "" {
+		info, err := store.GetRepoInfo(ctx, repoName)
+		if err != nil {
+			fatalf("failed to read repo info: %v", err)
+		}
+		layers.Backend = info
+	}
+	return settings.Resolve(layers)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forklift/internal/filestore"
+	"forklift/internal/repoconfig"
+	"forklift/internal/settings"
+	"forklift/internal/structures"
+)
+
+func TestRepoSettingsReadsBackend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	data := `{"repos": [{"repo": "org/repo", "branch": "dev", "tag_template": "build-{seq}"}]}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := filestore.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := structures.Config{TagTemplate: "v{major}.{minor}.{patch}"}
+
+	set := repoSettings(ctx, cfg, store, "org/repo", nil)
+	if set.TagTemplate.Value != "build-{seq}" || set.TagTemplate.Source != settings.SourceBackend {
+		t.Errorf("TagTemplate = %+v, want build-{seq} from the backend", set.TagTemplate)
+	}
+
+	set = repoSettings(ctx, cfg, store, "org/repo", &repoconfig.Config{Strategy: "squash"})
+	if set.Strategy.Value != "squash" || set.Strategy.Source != settings.SourceRepo {
+		t.Errorf("Strategy = %+v, want squash from %s", set.Strategy, repoconfig.FileName)
+	}
+
+	set = repoSettings(ctx, cfg, store, "", nil)
+	if set.TagTemplate.Value != cfg.TagTemplate || set.TagTemplate.Source != settings.SourceUser {
+		t.Errorf("TagTemplate without a repo = %+v, want the user config's", set.TagTemplate)
+	}
+}
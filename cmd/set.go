@@ -4,9 +4,8 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"forklift/internal/config"
 	"forklift/internal/git"
-	"forklift/internal/sheets"
+	"forklift/internal/repoconfig"
 	"os"
 	"strings"
 
@@ -27,20 +26,12 @@ var setBranchCmd = &cobra.Command{
 		if strings.TrimSpace(branch) == "" {
 			fatalf("branch name cannot be empty")
 		}
-
-		cfg, err := config.Load()
-		if err != nil {
-			fatalf("failed to load config: %v", err)
-		}
-		if cfg.SheetID == "" || cfg.CredentialsPath == "" {
-			fatalf("configuration not found. run 'forklift init' first.")
+		if repoCfg := loadRepoConfig(); !repoCfg.AllowsMergeBranch(branch) {
+			fatalf("merge branch %s is not allowed by %s, which allows %s", branch, repoconfig.FileName, strings.Join(repoCfg.MergeBranches, ", "))
 		}
 
 		ctx := context.Background()
-		service, err := sheets.NewService(ctx, cfg.CredentialsPath)
-		if err != nil {
-			fatalf("failed to initialize Google Sheets client: %v", err)
-		}
+		_, store := loadStore(ctx)
 
 		repoName, err := git.DetectRepoName()
 		if err != nil {
@@ -48,7 +39,7 @@ var setBranchCmd = &cobra.Command{
 		}
 
 		// Check if exists
-		info, err := service.GetRepoInfo(ctx, cfg.SheetID, cfg.SheetName, repoName)
+		info, err := store.GetRepoInfo(ctx, repoName)
 		if err != nil {
 			fatalf("failed to read repo info: %v", err)
 		}
@@ -67,7 +58,7 @@ var setBranchCmd = &cobra.Command{
 			}
 		}
 
-		if err := service.SetMergeBranch(ctx, cfg.SheetID, cfg.SheetName, repoName, branch, rowIdx); err != nil {
+		if err := store.SetMergeBranch(ctx, repoName, branch, rowIdx); err != nil {
 			fatalf("failed to set merge-branch: %v", err)
 		}
 		fmt.Printf("🌿 Merge branch set for %s: %s\n", repoName, branch)
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/backend"
+	"forklift/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var sheetCmd = &cobra.Command{
+	Use:   "sheet",
+	Short: "Manage the Google Sheet used as state backend",
+}
+
+var sheetSetupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Create and migrate the tabs forklift needs",
+	Long: `Create the merge branches, history, locks and meta tabs if they are missing,
+write their headers, migrate older layouts to the current version, freeze the
+header rows and apply data validation. Safe to run again at any time.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err != nil {
+			fatalf("failed to load config: %v", err)
+		}
+		if cfg.Backend != "" && cfg.Backend != backend.Sheets {
+			fatalf("sheet setup only applies to the %s backend (configured: %s)", backend.Sheets, cfg.Backend)
+		}
+
+		ctx := context.Background()
+		service, err := backend.NewSheets(ctx, cfg)
+		if err != nil {
+			fatalf("%v", err)
+		}
+
+		fmt.Println("🛠️  Setting up sheet...")
+		report, err := service.Setup(ctx)
+		for _, line := range report {
+			fmt.Printf("  ✔ %s\n", line)
+		}
+		if err != nil {
+			fatalf("sheet setup failed: %v", err)
+		}
+		fmt.Println("🏗️  Sheet is ready!")
+	},
+}
+
+func init() {
+	sheetCmd.AddCommand(sheetSetupCmd)
+	rootCmd.AddCommand(sheetCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/build"
+	"forklift/internal/git"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fixTags        bool
+	pruneLocalTags bool
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Inspect and repair the repository's build tags",
+}
+
+var tagDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Report drift between the backend, local tags and remote tags",
+	Long: `Compare the latest tag in the backend with the tags on origin and the local
+tags that match the repo's tag template. Reports a backend that is behind origin
+or points at a tag origin doesn't have, tags not fetched yet, local tags that
+were never pushed and tags that point at different commits locally and on origin.
+
+With --fix, fetches missing and mismatched tags and moves the backend up to the
+highest tag on origin. Local-only tags are kept unless --prune-local is given too.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		cfg, store := loadStore(ctx)
+
+		repoName, err := git.DetectRepoName()
+		if err != nil {
+			fatalf("failed to detect repo name: %v", err)
+		}
+
+		repoCfg := loadRepoConfig()
+		set := repoSettings(ctx, cfg, store, repoName, repoCfg)
+		opts := build.Options{TagTemplate: set.TagTemplate.Value, RepoConfig: repoCfg}
+		report, err := build.DiagnoseTags(ctx, store, repoName, opts)
+		if err != nil {
+			fatalf("tag doctor failed: %v", err)
+		}
+
+		fmt.Printf("🩺 Tags of %s on %s (template %s):\n", report.Repo, report.Branch, report.Template)
+		fmt.Printf("  Backend: %s\n", orDash(report.Backend))
+		fmt.Printf("  Origin:  %s\n", orDash(report.Remote))
+		fmt.Printf("  Local:   %s\n", orDash(report.Local))
+		if report.BackendBehind {
+			fmt.Printf("  ⚠ Backend is behind origin: %s is newer than %q\n", report.Remote, report.Backend)
+		}
+		if report.BackendMissing {
+			fmt.Printf("  ⚠ Backend tag %s does not exist on origin\n", report.Backend)
+		}
+		if len(report.Unfetched) > 0 {
+			fmt.Printf("  ⚠ On origin but not fetched: %s\n", strings.Join(report.Unfetched, ", "))
+		}
+		if len(report.LocalOnly) > 0 {
+			fmt.Printf("  ⚠ Local only, never pushed: %s\n", strings.Join(report.LocalOnly, ", "))
+		}
+		if len(report.Mismatched) > 0 {
+			fmt.Printf("  ⚠ On different commits locally and on origin: %s\n", strings.Join(report.Mismatched, ", "))
+		}
+
+		if report.Healthy() {
+			fmt.Println("✅ No drift found.")
+			return
+		}
+		if !fixTags {
+			fmt.Println("\nRun 'forklift tag doctor --fix' to repair.")
+			return
+		}
+
+		fmt.Println("🔧 Repairing...")
+		done, err := build.RepairTags(ctx, store, report, pruneLocalTags)
+		for _, line := range done {
+			fmt.Printf("  ✔ %s\n", line)
+		}
+		if err != nil {
+			fatalf("repair failed: %v", err)
+		}
+		if len(report.LocalOnly) > 0 && !pruneLocalTags {
+			fmt.Printf("Note: kept the local-only tags %s. Push them, or run 'forklift tag doctor --fix --prune-local' to delete them.\n", strings.Join(report.LocalOnly, ", "))
+		}
+		if report.BackendMissing && !report.BackendBehind {
+			fmt.Printf("Note: the backend still points at %s, which origin doesn't have. Fix it in the backend or push the tag.\n", report.Backend)
+		}
+	},
+}
+
+func init() {
+	tagDoctorCmd.Flags().BoolVar(&fixTags, "fix", false, "Repair the drift that was found")
+	tagDoctorCmd.Flags().BoolVar(&pruneLocalTags, "prune-local", false, "With --fix, also delete local tags that origin doesn't have")
+	tagCmd.AddCommand(tagDoctorCmd)
+	rootCmd.AddCommand(tagCmd)
+}
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"forklift/internal/config"
+	"forklift/internal/filestore"
+	"forklift/internal/sheets"
+	"forklift/internal/structures"
+
+	"google.golang.org/api/option"
+)
+
+// Backend names accepted in structures.Config.Backend.
+const (
+	Sheets = "sheets"
+	File   = "file"
+)
+
+// ErrNotConfigured is returned by New when the configuration does not describe a usable backend.
+var ErrNotConfigured = errors.New("configuration not found. run 'forklift init' first.")
+
+// Store is the state backend that holds the merge branch and latest tag of every repository.
+type Store interface {
+	// GetRepoInfo returns the stored information for repo.
+	// If the repository is not found, it returns nil and no error.
+	GetRepoInfo(ctx context.Context, repo string) (*structures.RepoInfo, error)
+
+	// SetMergeBranch sets the merge branch for repo and starts a new tag sequence.
+	// rowIdx is the RowIdx of the existing entry, or -1 to create a new one.
+	SetMergeBranch(ctx context.Context, repo, branch string, rowIdx int) error
+
+	// UpdateRepoTag records tag as the latest tag of the entry at rowIdx.
+	// It returns structures.ErrTagConflict if the entry no longer holds expectedTag.
+	UpdateRepoTag(ctx context.Context, rowIdx int, expectedTag, tag string) error
+
+	// AppendHistory adds a build to the tag history.
+	AppendHistory(ctx context.Context, record structures.HistoryRecord) error
+
+	// ListHistory returns the recorded builds of repo, oldest first.
+	ListHistory(ctx context.Context, repo string) ([]structures.HistoryRecord, error)
+
+	// AcquireLock takes the build lock of repo for owner until ttl has passed.
+	// Stale locks are taken over; a live lock of another owner is only taken
+	// over if steal is set, otherwise structures.ErrLockHeld is returned.
+	// Acquiring a lock owner already holds extends it.
+	AcquireLock(ctx context.Context, repo, owner string, ttl time.Duration, steal bool) (*structures.Lock, error)
+
+	// ReleaseLock releases the build lock of repo if owner holds it.
+	ReleaseLock(ctx context.Context, repo, owner string) error
+}
+
+// New returns the Store selected by cfg.
+func New(ctx context.Context, cfg structures.Config) (Store, error) {
+	switch cfg.Backend {
+	case "", Sheets:
+		service, err := NewSheets(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return service, nil
+	case File:
+		if cfg.StatePath == "" {
+			return nil, ErrNotConfigured
+		}
+		store, err := filestore.New(cfg.StatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state file: %w", err)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q (expected %q or %q)", cfg.Backend, Sheets, File)
+	}
+}
+
+// NewSheets returns the Google Sheets backend described by cfg, regardless of cfg.Backend.
+func NewSheets(ctx context.Context, cfg structures.Config) (*sheets.Service, error) {
+	// Credentials may only be omitted when talking to a custom endpoint
+	if cfg.SheetID == "" || (cfg.CredentialsPath == "" && cfg.SheetsEndpoint == "") {
+		return nil, ErrNotConfigured
+	}
+
+	var opts []option.ClientOption
+	if cfg.SheetsEndpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.SheetsEndpoint))
+	}
+
+	tabs := sheets.Tabs{
+		Repos:   orDefault(cfg.SheetName, config.DefaultSheetName),
+		History: orDefault(cfg.HistorySheet, config.DefaultHistorySheetName),
+		Locks:   orDefault(cfg.LockSheet, config.DefaultLockSheetName),
+		Meta:    orDefault(cfg.MetaSheet, config.DefaultMetaSheetName),
+	}
+
+	service, err := sheets.NewService(ctx, cfg.CredentialsPath, cfg.SheetID, tabs, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Google Sheets client: %w", err)
+	}
+	return service, nil
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
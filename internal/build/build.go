@@ -3,43 +3,126 @@ package build
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"forklift/internal/backend"
 	"forklift/internal/git"
-	"forklift/internal/sheets"
+	"forklift/internal/hooks"
+	"forklift/internal/notes"
+	"forklift/internal/repoconfig"
 	"forklift/internal/structures"
+	"forklift/internal/tagscheme"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
+	"time"
 )
 
-func Run(ctx context.Context, s *sheets.Service, sheetID, sheetName, repoName string) error {
+const (
+	// lockTTL is how long a build lock is valid before other builds may take it over.
+	lockTTL = 10 * time.Minute
+	// maxTagAttempts bounds how often a build recomputes its tag after losing a race.
+	maxTagAttempts = 5
+)
+
+// Options controls a build merge run.
+type Options struct {
+	// StealLock takes over the build lock even if another build still holds it.
+	StealLock bool
+	// Worktree merges in a temporary worktree instead of the current checkout.
+	Worktree bool
+	// TagTemplate is the tag template, as resolved by package settings from
+	// the flags, .forklift.yaml, the backend and the user config. Empty means
+	// tagscheme.Default.
+	TagTemplate string
+	// Bump is the semver part to bump (major, minor or patch). Empty bumps
+	// the smallest counter of the tag template.
+	Bump string
+	// Pre makes the new tag a pre-release with this identifier, e.g. rc.
+	Pre string
+	// NewSequence starts a new tag sequence if the latest tag doesn't match
+	// the tag template, instead of refusing to build.
+	NewSequence bool
+	// TagType is TagLightweight (the default when empty), TagAnnotated or TagSigned.
+	TagType string
+	// Notes is the format of the release notes printed after a successful
+	// build: notes.Markdown (the default when empty), notes.JSON or NotesNone.
+	Notes string
+	// Release publishes a GitHub release for the new tag. Nil turns it off.
+	Release *ReleaseOptions
+	// Strategy is the merge strategy, as resolved by package settings:
+	// StrategyMerge (the default when empty), StrategySquash, StrategyRebase
+	// or StrategyFastForward.
+	Strategy string
+	// NoHooks skips the repository's hooks.
+	NoHooks bool
+	// RepoConfig is the repository's .forklift.yaml, if any. It restricts the
+	// merge branches builds may target.
+	RepoConfig *repoconfig.Config
+}
+
+// Tag types.
+const (
+	TagLightweight = "lightweight"
+	TagAnnotated   = "annotated"
+	TagSigned      = "signed"
+)
+
+func Run(ctx context.Context, store backend.Store, repoName string, opts Options) error {
 	statePath, err := GetStatePath()
 	if err == nil {
 		if _, err := os.Stat(statePath); err == nil {
-			return Resume(ctx, s, sheetID, sheetName, statePath)
+			return Resume(ctx, store, statePath, opts)
 		}
 	}
 
 	// 1. Get Repo Info
-	info, err := s.GetRepoInfo(ctx, sheetID, sheetName, repoName)
+	info, err := store.GetRepoInfo(ctx, repoName)
 	if err != nil {
 		return fmt.Errorf("failed to get repo info: %w", err)
 	}
 	if info == nil {
-		return fmt.Errorf("repo %s not found in sheet", repoName)
+		return fmt.Errorf("repo %s not found in backend", repoName)
 	}
 	if info.MergeBranch == "" {
 		return fmt.Errorf("merge-branch not set for %s", repoName)
 	}
-
-	// 2. Git Stash
-	fmt.Println("📦 Stashing changes...")
-	stashed, err := git.Stash()
+	if !opts.RepoConfig.AllowsMergeBranch(info.MergeBranch) {
+		return fmt.Errorf("merge branch %s is not allowed by %s, which allows %s", info.MergeBranch, repoconfig.FileName, strings.Join(opts.RepoConfig.MergeBranches, ", "))
+	}
+	// Catch a broken tag template or bump before merging rather than after pushing
+	tmpl, err := tagTemplate(opts)
 	if err != nil {
-		return fmt.Errorf("git stash failed: %w", err)
+		return err
+	}
+	if err := tmpl.CheckBump(tagscheme.Bump{Level: opts.Bump, Pre: opts.Pre}); err != nil {
+		return err
+	}
+	if err := checkSequence(tmpl, info.LatestTag, opts); err != nil {
+		return err
+	}
+	strategy, err := Strategy(opts)
+	if err != nil {
+		return err
+	}
+	switch opts.TagType {
+	case "", TagLightweight, TagAnnotated, TagSigned:
+	default:
+		return fmt.Errorf("unknown tag type %q, want %s, %s or %s", opts.TagType, TagLightweight, TagAnnotated, TagSigned)
+	}
+	switch opts.Notes {
+	case "", notes.Markdown, notes.JSON, NotesNone:
+	default:
+		return fmt.Errorf("unknown release notes format %q, want %s, %s or %s", opts.Notes, notes.Markdown, notes.JSON, NotesNone)
+	}
+	if opts.Release != nil && opts.Release.Token == "" {
+		return fmt.Errorf("publishing GitHub releases needs a GitHub token. Run 'forklift init' to add one")
+	}
+	if opts.Release != nil {
+		// A missing artifact would otherwise only fail the release, after the tag was pushed
+		if _, err := expandAssets(opts.Release.Assets); err != nil {
+			return err
+		}
 	}
 
 	originalBranch, err := git.CurrentBranch()
@@ -47,14 +130,28 @@ func Run(ctx context.Context, s *sheets.Service, sheetID, sheetName, repoName st
 		return fmt.Errorf("failed to get current branch: %w", err)
 	}
 
-	// Save state before switching branches
 	state := structures.BuildState{
 		OriginalBranch: originalBranch,
 		MergeBranch:    info.MergeBranch,
-		Stashed:        stashed,
 		RepoName:       repoName,
 		RowIdx:         info.RowIdx,
+		Bump:           opts.Bump,
+		Pre:            opts.Pre,
+		Strategy:       strategy,
+	}
+	if opts.Worktree {
+		return runInWorktree(ctx, store, state, info.LatestTag, opts)
+	}
+
+	// 2. Git Stash
+	fmt.Println("📦 Stashing changes...")
+	stashed, err := git.Stash()
+	if err != nil {
+		return fmt.Errorf("git stash failed: %w", err)
 	}
+	state.Stashed = stashed
+
+	// Save state before switching branches
 	if err := SaveState(state); err != nil {
 		fmt.Printf("Warning: failed to save build state: %v\n", err)
 	}
@@ -77,156 +174,412 @@ func Run(ctx context.Context, s *sheets.Service, sheetID, sheetName, repoName st
 	if err := git.Pull("origin", info.MergeBranch); err != nil {
 		return fmt.Errorf("failed to pull %s: %w", info.MergeBranch, err)
 	}
+	if err := beforeMerge(ctx, store, &state, opts); err != nil {
+		return err
+	}
 
 	// 4. Merge Original Branch
-	fmt.Printf("🔀 Merging %s into %s...\n", originalBranch, info.MergeBranch)
-	if err := git.Merge(originalBranch); err != nil {
-		if git.IsMergeInProgress() {
+	fmt.Printf("🔀 Merging %s into %s (%s)...\n", originalBranch, info.MergeBranch, strategy)
+	if err := mergeBranch(git.Default, state); err != nil {
+		if mergeInProgress(git.Default) {
 			skipCleanup = true
+			recordHistory(ctx, store, state, "", structures.OutcomeConflict)
 			fmt.Println("\n⚠️  MERGE CONFLICTS DETECTED!")
-			fmt.Println("Please resolve the conflicts manually, commit the changes, and then run 'forklift build merge' again to finish.")
-			fmt.Println("Note: You are currently on the " + info.MergeBranch + " branch.")
+			fmt.Println(ConflictHelp(strategy) + ", and then run 'forklift build merge' again to finish.")
+			if strategy == StrategyRebase {
+				fmt.Println("Note: You are rebasing a copy of your branch, " + rebaseBranch + "; your own branch is not touched.")
+			} else {
+				fmt.Println("Note: You are currently on the " + info.MergeBranch + " branch.")
+			}
 			return nil
 		}
-		return fmt.Errorf("merge failed: %w", err)
+		return fail(ctx, store, state, "", fmt.Errorf("merge failed: %w", err), opts)
 	}
+	completeStep(&state, StepMerge)
 
-	return Finish(ctx, s, sheetID, sheetName, state, info.LatestTag)
+	if err := Finish(ctx, store, &state, info.LatestTag, opts); err != nil {
+		skipCleanup = !errors.Is(err, ErrRolledBack)
+		return err
+	}
+	return nil
 }
 
-func Resume(ctx context.Context, s *sheets.Service, sheetID, sheetName, statePath string) error {
-	data, err := os.ReadFile(statePath)
+// runInWorktree merges into a temporary worktree of the merge branch, so the
+// developer's checkout, working tree and stash are never touched.
+func runInWorktree(ctx context.Context, store backend.Store, state structures.BuildState, lastTag string, opts Options) error {
+	// 2. Fetch Merge Branch and Create Worktree
+	fmt.Printf("📥 Fetching %s...\n", state.MergeBranch)
+	if err := git.Fetch("origin", state.MergeBranch); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", state.MergeBranch, err)
+	}
+
+	path, err := os.MkdirTemp("", "forklift-worktree-")
 	if err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	fmt.Printf("🌲 Creating worktree for %s at %s...\n", state.MergeBranch, path)
+	if err := git.AddWorktree(path, state.MergeBranch); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to create worktree for %s: %w", state.MergeBranch, err)
+	}
+	state.WorktreePath = path
+
+	if err := SaveState(state); err != nil {
+		fmt.Printf("Warning: failed to save build state: %v\n", err)
+	}
+
+	skipCleanup := false
+	defer func() {
+		if !skipCleanup {
+			Cleanup(state)
+		}
+	}()
+
+	// 3. Pull and Merge Original Branch in the Worktree
+	wt := gitFor(state)
+	fmt.Printf("📥 Pulling latest for %s...\n", state.MergeBranch)
+	if err := wt.Pull("origin", state.MergeBranch); err != nil {
+		return fmt.Errorf("failed to pull %s: %w", state.MergeBranch, err)
+	}
+	if err := beforeMerge(ctx, store, &state, opts); err != nil {
 		return err
 	}
-	var state structures.BuildState
-	if err := json.Unmarshal(data, &state); err != nil {
+
+	fmt.Printf("🔀 Merging %s into %s (%s)...\n", state.OriginalBranch, state.MergeBranch, state.Strategy)
+	if err := mergeBranch(wt, state); err != nil {
+		if mergeInProgress(wt) {
+			skipCleanup = true
+			recordHistory(ctx, store, state, "", structures.OutcomeConflict)
+			fmt.Println("\n⚠️  MERGE CONFLICTS DETECTED!")
+			fmt.Printf("%s in the worktree:\n\n    cd %s\n\n", ConflictHelp(state.Strategy), path)
+			fmt.Println("Then run 'forklift build merge' again (from the worktree or your checkout) to finish.")
+			fmt.Println("Note: Your own checkout was not touched.")
+			return nil
+		}
+		return fail(ctx, store, state, "", fmt.Errorf("merge failed: %w", err), opts)
+	}
+	completeStep(&state, StepMerge)
+
+	if err := Finish(ctx, store, &state, lastTag, opts); err != nil {
+		skipCleanup = !errors.Is(err, ErrRolledBack)
+		return err
+	}
+	return nil
+}
+
+// beforeMerge records where the merge branch is before the merge, so an
+// unpushed merge can be rolled back, and runs the pre-merge hooks.
+func beforeMerge(ctx context.Context, store backend.Store, state *structures.BuildState, opts Options) error {
+	base, err := gitFor(*state).HeadCommit()
+	if err != nil {
+		return fmt.Errorf("failed to get head of %s: %w", state.MergeBranch, err)
+	}
+	state.MergeBase = base
+	if err := SaveState(*state); err != nil {
+		fmt.Printf("Warning: failed to save build state: %v\n", err)
+	}
+	if err := runHooks(*state, hooks.PreMerge, opts, nil); err != nil {
+		return fail(ctx, store, *state, "", err, opts)
+	}
+	return nil
+}
+
+func Resume(ctx context.Context, store backend.Store, statePath string, opts Options) error {
+	state, err := readState(statePath)
+	if err != nil {
 		return err
 	}
 
-	fmt.Println("⏯️  Detected previous build in progress. Resuming...")
+	fmt.Printf("⏯️  Detected previous build in progress. Resuming at step %q...\n", NextStep(state))
+	if opts.Bump != state.Bump || opts.Pre != state.Pre {
+		fmt.Println("Note: --bump and --pre only apply to new builds; resuming with the ones the build was started with.")
+	}
+	if opts.Strategy != "" && opts.Strategy != state.Strategy {
+		fmt.Printf("Note: the merge strategy %s only applies to new builds; resuming with %s.\n", opts.Strategy, state.Strategy)
+	}
 
-	if git.IsMergeInProgress() {
-		return fmt.Errorf("merge is still in progress. Please resolve conflicts and commit first.")
+	g := gitFor(state)
+	if mergeInProgress(g) {
+		what := "merge"
+		if g.IsRebaseInProgress() {
+			what = "rebase"
+		}
+		if state.WorktreePath != "" {
+			return fmt.Errorf("%s is still in progress in %s. %s there first.", what, state.WorktreePath, ConflictHelp(state.Strategy))
+		}
+		return fmt.Errorf("%s is still in progress. %s first.", what, ConflictHelp(state.Strategy))
+	}
+	// A rebase that stopped on conflicts was continued by hand; fast-forward to it
+	if !stepDone(state, StepMerge) && state.Strategy == StrategyRebase {
+		if current, _ := g.CurrentBranch(); current == rebaseBranch {
+			if err := finishRebase(g, state); err != nil {
+				return fmt.Errorf("failed to fast-forward %s to the rebased branch: %w", state.MergeBranch, err)
+			}
+		}
 	}
 
 	// Check if we are on the right branch
-	current, _ := git.CurrentBranch()
+	current, _ := g.CurrentBranch()
 	if current != state.MergeBranch {
 		return fmt.Errorf("you are on branch %s, but build state says were merging into %s. Please switch and resolve conflicts.", current, state.MergeBranch)
 	}
+	// The conflicts were resolved and committed by hand
+	if !stepDone(state, StepMerge) {
+		completeStep(&state, StepMerge)
+	}
 
 	// Get latest tag again to be sure
-	info, err := s.GetRepoInfo(ctx, sheetID, sheetName, state.RepoName)
+	info, err := store.GetRepoInfo(ctx, state.RepoName)
 	if err != nil {
 		return err
 	}
 	if info == nil {
-		return fmt.Errorf("repo %s not found in sheet", state.RepoName)
+		return fmt.Errorf("repo %s not found in backend", state.RepoName)
 	}
 
-	err = Finish(ctx, s, sheetID, sheetName, state, info.LatestTag)
-	if err == nil {
+	err = Finish(ctx, store, &state, info.LatestTag, opts)
+	if err == nil || errors.Is(err, ErrRolledBack) {
 		Cleanup(state)
 	}
 	return err
 }
 
-func Finish(ctx context.Context, s *sheets.Service, sheetID, sheetName string, state structures.BuildState, lastTag string) error {
-	// 5. Determine New Tag (and handle existing tags)
-	newTag, err := IncrementTag(lastTag, state.MergeBranch)
+// Finish runs the build steps the state has not completed yet, journaling each
+// one, and records the outcome in the tag history. On failure the state is
+// kept so the next run resumes at the failed step.
+func Finish(ctx context.Context, store backend.Store, state *structures.BuildState, lastTag string, opts Options) error {
+	newTag, err := finish(ctx, store, state, lastTag, opts)
 	if err != nil {
+		// on-failure hooks still see the merged code
+		fail(ctx, store, *state, newTag, err, opts)
+		var rb *rollbackError
+		if errors.As(err, &rb) {
+			err = rollbackMerge(gitFor(*state), *state, rb.err)
+		}
+		if !errors.Is(err, ErrRolledBack) {
+			fmt.Printf("💾 Progress saved. Run 'forklift build merge' to resume at step %q, or 'forklift build abort' to give up.\n", NextStep(*state))
+		}
 		return err
 	}
+	recordHistory(ctx, store, *state, newTag, structures.OutcomeSuccess)
 
-	for git.TagExists(newTag) {
-		fmt.Printf("Tag %s already exists, incrementing further...\n", newTag)
-		newTag, err = IncrementTag(newTag, state.MergeBranch)
-		if err != nil {
-			return err
+	fmt.Println("🏗️  Build merge completed successfully! 🎉")
+	if err := runHooks(*state, hooks.PostTag, opts, nil); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	printNotes(state, opts)
+	return nil
+}
+
+// lockOwner identifies this process in the build lock.
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s (pid %d on %s)", git.UserIdentity(), os.Getpid(), host)
+}
+
+func Cleanup(state structures.BuildState) {
+	main := mainGit()
+	if state.WorktreePath != "" {
+		fmt.Printf("🧹 Removing worktree %s...\n", state.WorktreePath)
+		if err := main.RemoveWorktree(state.WorktreePath); err != nil {
+			fmt.Printf("Warning: failed to remove worktree: %v\n", err)
+		}
+	} else {
+		if current, _ := git.CurrentBranch(); current != state.OriginalBranch {
+			fmt.Printf("⬅️  Switching back to %s...\n", state.OriginalBranch)
+			if err := git.Checkout(state.OriginalBranch); err != nil {
+				fmt.Printf("Warning: failed to switch back to %s: %v\n", state.OriginalBranch, err)
+			}
+		}
+		if state.Stashed {
+			popStash()
 		}
 	}
-	fmt.Printf("🏷️  New tag: %s\n", newTag)
+	// Left behind by a rebase that stopped or failed
+	if state.Strategy == StrategyRebase && main.BranchExists(rebaseBranch) {
+		if err := main.DeleteBranch(rebaseBranch); err != nil {
+			fmt.Printf("Warning: failed to delete branch %s: %v\n", rebaseBranch, err)
+		}
+	}
+	path, _ := GetStatePath()
+	if path != "" {
+		os.Remove(path)
+	}
+}
 
-	// 6. Push Merge Branch (Commit)
-	fmt.Println("📤 Pushing merge commit...")
-	if err := git.PushBranch("origin", state.MergeBranch); err != nil {
-		return fmt.Errorf("failed to push branch %s: %w", state.MergeBranch, err)
+// popStash restores the changes forklift stashed, leaving any other stash alone.
+func popStash() {
+	fmt.Println("🔓 Popping stash...")
+	ref, err := git.FindStash(git.StashMessage)
+	if err == nil && ref == "" {
+		err = fmt.Errorf("no stash named %s found", git.StashMessage)
+	}
+	if err == nil {
+		err = git.PopStash(ref)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to pop stash, your changes are still in 'git stash list': %v\n", err)
 	}
+}
 
-	// 7. Create and Push Tag
-	fmt.Println("🏷️  Creating tag...")
-	if err := git.Tag(newTag); err != nil {
-		return fmt.Errorf("failed to create tag %s: %w", newTag, err)
+// Status describes the build in progress in the current repository.
+type Status struct {
+	// State is the saved build state, or nil when no build is in progress.
+	State           *structures.BuildState
+	CurrentBranch   string
+	MergeInProgress bool
+	// StashRef is the forklift stash, or "" if there is none.
+	StashRef string
+}
+
+// GetStatus reports the saved build state along with the git state it refers to.
+func GetStatus() (*Status, error) {
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
 	}
+	status := &Status{State: state}
+	g := git.Default
+	if state != nil {
+		g = gitFor(*state)
+	}
+	status.CurrentBranch, _ = g.CurrentBranch()
+	status.MergeInProgress = mergeInProgress(g)
+	if status.StashRef, err = git.FindStash(git.StashMessage); err != nil {
+		return nil, fmt.Errorf("failed to list stashes: %w", err)
+	}
+	return status, nil
+}
 
-	fmt.Println("🚀 Pushing tag...")
-	if err := git.PushTag("origin", newTag); err != nil {
-		return fmt.Errorf("failed to push tag %s: %w", newTag, err)
+// Abort gives up on the build in progress: it aborts the merge, undoes the
+// merge if it wasn't pushed yet, puts the developer back on their original
+// branch with their stashed changes, and removes the saved state.
+func Abort() error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("no build in progress")
 	}
 
-	// 8. Update Sheet
-	fmt.Println("📊 Updating sheet...")
-	if err := s.UpdateRepoTag(ctx, sheetID, sheetName, state.RowIdx, newTag); err != nil {
-		return fmt.Errorf("failed to update sheet: %w", err)
+	g := gitFor(*state)
+	if err := abortMerge(g); err != nil {
+		return fmt.Errorf("failed to abort merge: %w", err)
+	}
+	if !stepDone(*state, StepPushBranch) {
+		resetMergeBranch(g, *state)
 	}
 
-	fmt.Println("🏗️  Build merge completed successfully! 🎉")
+	Cleanup(*state)
+	fmt.Println("🛑 Build aborted.")
 	return nil
 }
 
-func Cleanup(state structures.BuildState) {
-	if current, _ := git.CurrentBranch(); current != state.OriginalBranch {
-		fmt.Printf("⬅️  Switching back to %s...\n", state.OriginalBranch)
-		git.Checkout(state.OriginalBranch)
+// resetMergeBranch resets the merge branch to where it was before the
+// unpushed merge of an aborted build, so it isn't left ahead of origin. If
+// it can't, it warns instead.
+func resetMergeBranch(g *git.Runner, state structures.BuildState) {
+	if state.MergeBase == "" {
+		if stepDone(state, StepMerge) {
+			fmt.Printf("Warning: %s still has the unpushed merge and is ahead of origin; reset it with 'git reset --hard origin/%s'\n", state.MergeBranch, state.MergeBranch)
+		}
+		return
 	}
-	if state.Stashed {
-		fmt.Println("🔓 Popping stash...")
-		git.StashPop()
+	if head, err := g.Run("rev-parse", state.MergeBranch); err == nil && head == state.MergeBase {
+		return
 	}
-	path, _ := GetStatePath()
-	if path != "" {
-		os.Remove(path)
+	if current, _ := g.CurrentBranch(); current != state.MergeBranch {
+		fmt.Printf("Warning: %s still has the unpushed merge and is ahead of origin; reset it with 'git reset --hard %.7s' on it\n", state.MergeBranch, state.MergeBase)
+		return
+	}
+	fmt.Printf("⏪ Undoing the unpushed merge, resetting %s to %.7s...\n", state.MergeBranch, state.MergeBase)
+	if err := g.ResetHard(state.MergeBase); err != nil {
+		fmt.Printf("Warning: failed to reset %s, it is still ahead of origin: %v\n", state.MergeBranch, err)
+	}
+}
+
+// gitFor returns the runner for the checkout the build merges in.
+func gitFor(state structures.BuildState) *git.Runner {
+	if state.WorktreePath != "" {
+		return &git.Runner{Dir: state.WorktreePath}
+	}
+	return git.Default
+}
+
+// mainGit returns a runner that works even when the current directory is a
+// worktree about to be removed, by running inside the shared git directory.
+func mainGit() *git.Runner {
+	dir, err := git.GitDir()
+	if err != nil {
+		return git.Default
+	}
+	return &git.Runner{Dir: dir}
+}
+
+// recordHistory appends the build to the tag history. Failing to record is
+// reported but never fails the build itself.
+func recordHistory(ctx context.Context, store backend.Store, state structures.BuildState, tag, outcome string) {
+	sha, _ := gitFor(state).HeadCommit()
+	record := structures.HistoryRecord{
+		Repo:         state.RepoName,
+		MergeBranch:  state.MergeBranch,
+		Tag:          tag,
+		CommitSHA:    sha,
+		SourceBranch: state.OriginalBranch,
+		User:         git.UserIdentity(),
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Outcome:      outcome,
+	}
+	if err := store.AppendHistory(ctx, record); err != nil {
+		fmt.Printf("Warning: failed to record build history: %v\n", err)
 	}
 }
 
 func GetStatePath() (string, error) {
-	out, err := exec.Command("git", "rev-parse", "--git-dir").Output()
+	dir, err := git.GitDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(strings.TrimSpace(string(out)), "forklift_build_state.json"), nil
+	return filepath.Join(dir, "forklift_build_state.json"), nil
 }
 
-func SaveState(state structures.BuildState) error {
+// LoadState returns the saved build state, or nil if no build is in progress.
+func LoadState() (*structures.BuildState, error) {
 	path, err := GetStatePath()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	state, err := readState(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
 	}
-	data, err := json.Marshal(state)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return os.WriteFile(path, data, 0600)
+	return &state, nil
 }
 
-func IncrementTag(lastTag, branchName string) (string, error) {
-	if lastTag == "" {
-		return fmt.Sprintf("v-%s-0.0.1", branchName), nil
+func readState(path string) (structures.BuildState, error) {
+	var state structures.BuildState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
 	}
-
-	// Try finding semantic version pattern with dots at the end (e.g. .1)
-	reSem := regexp.MustCompile(`^(.*?)(\d+)$`)
-	matchesSem := reSem.FindStringSubmatch(lastTag)
-	if len(matchesSem) == 3 {
-		prefix := matchesSem[1]
-		numStr := matchesSem[2]
-		num, err := strconv.Atoi(numStr)
-		if err != nil {
-			return "", err
-		}
-		return fmt.Sprintf("%s%d", prefix, num+1), nil
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse build state %s: %w", path, err)
 	}
+	return state, nil
+}
 
-	return lastTag + ".1", nil
+func SaveState(state structures.BuildState) error {
+	path, err := GetStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
 }
@@ -0,0 +1,141 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/backend"
+	"forklift/internal/git"
+	"forklift/internal/tagscheme"
+	"slices"
+)
+
+// TagReport describes how the backend's latest tag, the local tags and the
+// remote tags of a repo relate. Only tags matching the repo's tag template
+// for its merge branch are considered.
+type TagReport struct {
+	Repo     string
+	Branch   string
+	Template string
+
+	Backend string // latest tag in the backend
+	Remote  string // highest tag on origin
+	Local   string // highest local tag
+
+	BackendBehind  bool     // origin has a newer tag than the backend
+	BackendMissing bool     // the backend's tag does not exist on origin
+	Unfetched      []string // on origin but not local
+	LocalOnly      []string // local but not on origin, e.g. left by a failed push
+	Mismatched     []string // local and on origin, but on different commits
+
+	rowIdx int
+}
+
+// Healthy reports whether no drift was found.
+func (r *TagReport) Healthy() bool {
+	return !r.BackendBehind && !r.BackendMissing && len(r.Unfetched) == 0 && len(r.LocalOnly) == 0 && len(r.Mismatched) == 0
+}
+
+// DiagnoseTags compares the backend's latest tag with the local and remote tags.
+func DiagnoseTags(ctx context.Context, store backend.Store, repoName string, opts Options) (*TagReport, error) {
+	info, err := store.GetRepoInfo(ctx, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo info: %w", err)
+	}
+	if info == nil {
+		return nil, fmt.Errorf("repo %s not found in backend", repoName)
+	}
+	if info.MergeBranch == "" {
+		return nil, fmt.Errorf("merge-branch not set for %s", repoName)
+	}
+	tmpl, err := tagTemplate(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := git.RemoteTags("origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote tags: %w", err)
+	}
+	local, err := git.LocalTags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local tags: %w", err)
+	}
+	// The tag of a build in progress is expected to be local only
+	var pending string
+	if state, _ := LoadState(); state != nil {
+		pending = state.Tag
+	}
+
+	r := &TagReport{
+		Repo:     repoName,
+		Branch:   info.MergeBranch,
+		Template: tmpl.String(),
+		Backend:  info.LatestTag,
+		rowIdx:   info.RowIdx,
+	}
+	matching := func(tags map[string]string) []string {
+		var out []string
+		for tag := range tags {
+			if v, ok := tmpl.Match(tag); ok && (!tmpl.Has(tagscheme.TokenBranch) || v.Branch == info.MergeBranch) {
+				out = append(out, tag)
+			}
+		}
+		slices.Sort(out)
+		return out
+	}
+	remoteTags, localTags := matching(remote), matching(local)
+	r.Remote = tmpl.Highest(remoteTags, info.MergeBranch)
+	r.Local = tmpl.Highest(localTags, info.MergeBranch)
+
+	r.BackendBehind = tmpl.Newer(r.Remote, r.Backend)
+	_, onRemote := remote[r.Backend]
+	r.BackendMissing = r.Backend != "" && !onRemote
+	for _, tag := range remoteTags {
+		if _, ok := local[tag]; !ok {
+			r.Unfetched = append(r.Unfetched, tag)
+		}
+	}
+	for _, tag := range localTags {
+		sha, ok := remote[tag]
+		switch {
+		case !ok && tag != pending:
+			r.LocalOnly = append(r.LocalOnly, tag)
+		case ok && sha != local[tag]:
+			r.Mismatched = append(r.Mismatched, tag)
+		}
+	}
+	return r, nil
+}
+
+// RepairTags fixes the drift in r: it fetches missing and mismatched tags
+// from origin and moves the backend up to origin's highest tag. Local tags
+// origin doesn't have may be kept on purpose, so they are only deleted with
+// pruneLocal. A backend tag missing from origin can't be repaired
+// automatically. It returns a description of each change made.
+func RepairTags(ctx context.Context, store backend.Store, r *TagReport, pruneLocal bool) ([]string, error) {
+	var done []string
+
+	if fetch := append(slices.Clone(r.Unfetched), r.Mismatched...); len(fetch) > 0 {
+		if err := git.FetchTags("origin", fetch...); err != nil {
+			return done, fmt.Errorf("failed to fetch tags: %w", err)
+		}
+		done = append(done, fmt.Sprintf("Fetched %d tag(s) from origin", len(fetch)))
+	}
+
+	if pruneLocal {
+		for _, tag := range r.LocalOnly {
+			if err := git.DeleteTag(tag); err != nil {
+				return done, fmt.Errorf("failed to delete local tag %s: %w", tag, err)
+			}
+			done = append(done, fmt.Sprintf("Deleted local tag %s, which origin doesn't have", tag))
+		}
+	}
+
+	if r.BackendBehind {
+		if err := store.UpdateRepoTag(ctx, r.rowIdx, r.Backend, r.Remote); err != nil {
+			return done, fmt.Errorf("failed to update backend: %w", err)
+		}
+		done = append(done, fmt.Sprintf("Updated backend tag from %q to %s", r.Backend, r.Remote))
+	}
+	return done, nil
+}
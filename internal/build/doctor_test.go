@@ -0,0 +1,36 @@
+package build
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"forklift/internal/git"
+)
+
+func TestRepairTagsKeepsLocalOnlyTags(t *testing.T) {
+	for _, prune := range []bool{false, true} {
+		t.Run(map[bool]string{false: "keep", true: "prune"}[prune], func(t *testing.T) {
+			newTestRepo(t)
+			ctx := context.Background()
+			store := newTestStore(t)
+			gitRun(t, "tag", "v-dev-0.0.5", "dev")
+
+			report, err := DiagnoseTags(ctx, store, "org/repo", Options{})
+			if err != nil {
+				t.Fatalf("DiagnoseTags() error = %v", err)
+			}
+			if want := []string{"v-dev-0.0.5"}; !reflect.DeepEqual(report.LocalOnly, want) {
+				t.Fatalf("LocalOnly = %v, want %v", report.LocalOnly, want)
+			}
+
+			done, err := RepairTags(ctx, store, report, prune)
+			if err != nil {
+				t.Fatalf("RepairTags() error = %v", err)
+			}
+			if got := git.TagExists("v-dev-0.0.5"); got == prune {
+				t.Errorf("tag exists = %v after RepairTags(prune=%v), changes %q", got, prune, done)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forklift/internal/backend"
+	"forklift/internal/git"
+	"forklift/internal/hooks"
+	"forklift/internal/structures"
+)
+
+// ErrRolledBack is wrapped by the error of a build whose unpushed merge was
+// undone, e.g. because a post-merge or pre-push hook failed. There is
+// nothing left to resume.
+var ErrRolledBack = errors.New("merge rolled back")
+
+// runHooks runs the hooks of phase in the build's checkout. They are loaded
+// from the checkout each time, so pre-merge hooks come from the merge branch
+// and later ones from the merged code.
+func runHooks(state structures.BuildState, phase string, opts Options, buildErr error) error {
+	if opts.NoHooks {
+		return nil
+	}
+	g := gitFor(state)
+	root, err := g.TopLevel()
+	if err != nil {
+		return fmt.Errorf("failed to find repository root: %w", err)
+	}
+	set, err := hooks.Load(root)
+	if err != nil {
+		return fmt.Errorf("failed to load hooks: %w", err)
+	}
+	if len(set[phase]) == 0 {
+		return nil
+	}
+
+	commit, _ := g.HeadCommit()
+	c := hooks.Context{
+		Repo:         state.RepoName,
+		SourceBranch: state.OriginalBranch,
+		MergeBranch:  state.MergeBranch,
+		Strategy:     state.Strategy,
+		Tag:          state.Tag,
+		PreviousTag:  state.PrevTag,
+		Commit:       commit,
+	}
+	if buildErr != nil {
+		c.Error = buildErr.Error()
+	}
+	fmt.Printf("🪝 Running %s hooks...\n", phase)
+	return set.Run(phase, root, c)
+}
+
+// fail records a failed build in the tag history and runs the on-failure
+// hooks. It returns err.
+func fail(ctx context.Context, store backend.Store, state structures.BuildState, tag string, err error, opts Options) error {
+	recordHistory(ctx, store, state, tag, structures.OutcomeFailed)
+	if hookErr := runHooks(state, hooks.OnFailure, opts, err); hookErr != nil {
+		fmt.Printf("Warning: %v\n", hookErr)
+	}
+	return err
+}
+
+// rollbackError marks a failure after which the unpushed merge must be
+// rolled back.
+type rollbackError struct{ err error }
+
+func (e *rollbackError) Error() string { return e.err.Error() }
+func (e *rollbackError) Unwrap() error { return e.err }
+
+// rollbackMerge resets the merge branch to where it was before the merge,
+// dropping the merge commit that was never pushed. It returns cause, marked
+// with ErrRolledBack if the rollback worked.
+func rollbackMerge(g *git.Runner, state structures.BuildState, cause error) error {
+	if state.MergeBase == "" {
+		return fmt.Errorf("%w; the merge was not rolled back, the commit before it is unknown", cause)
+	}
+	fmt.Printf("⏪ Rolling back the unpushed merge, resetting %s to %.7s...\n", state.MergeBranch, state.MergeBase)
+	if err := g.ResetHard(state.MergeBase); err != nil {
+		return fmt.Errorf("%w; rolling back the merge failed too: %v", cause, err)
+	}
+	return fmt.Errorf("%w (%w)", cause, ErrRolledBack)
+}
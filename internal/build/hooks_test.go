@@ -0,0 +1,82 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"forklift/internal/git"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	newTestRepo(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+	log := filepath.Join(t.TempDir(), "hooks.log")
+	t.Setenv("HOOK_LOG", log)
+	before := gitRun(t, "rev-parse", "dev")
+
+	commitFile(t, ".forklift.yaml", `hooks:
+  post-merge: ['test -f .forklift.yaml']
+  pre-push: ['exit 1']
+  on-failure: ['echo "on-failure $FORKLIFT_ERROR" >> "$HOOK_LOG"']
+`, "ci: add hooks")
+
+	err := Run(ctx, store, "org/repo", Options{})
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Run() error = %v, want rolled back merge", err)
+	}
+	if got := gitRun(t, "rev-parse", "dev"); got != before {
+		t.Errorf("dev = %s after rollback, want %s", got, before)
+	}
+	if state, _ := LoadState(); state != nil {
+		t.Errorf("state kept after rollback: %+v", state)
+	}
+	if branch, _ := git.CurrentBranch(); branch != "feature" {
+		t.Errorf("current branch = %s, want feature", branch)
+	}
+	if git.RemoteTagExists("origin", "v-dev-0.0.1") {
+		t.Error("tag was pushed despite the failing pre-push hook")
+	}
+	data, _ := os.ReadFile(log)
+	if got, want := string(data), "on-failure pre-push hook \"exit 1\" failed: exit status 1\n"; got != want {
+		t.Errorf("hook log = %q, want %q", got, want)
+	}
+
+	commitFile(t, ".forklift.yaml", `hooks:
+  pre-merge: ['echo "pre-merge $FORKLIFT_MERGE_BRANCH" >> "$HOOK_LOG"']
+  post-tag: ['echo "post-tag $FORKLIFT_TAG $FORKLIFT_COMMIT" >> "$HOOK_LOG"']
+`, "ci: drop pre-push hook")
+	os.Remove(log)
+	if err := Run(ctx, store, "org/repo", Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	data, _ = os.ReadFile(log)
+	if want := "post-tag v-dev-0.0.1 " + gitRun(t, "rev-parse", "origin/dev") + "\n"; string(data) != want {
+		t.Errorf("hook log = %q, want %q", data, want)
+	}
+
+	// Pre-merge hooks come from the merge branch, which has them now
+	commitFile(t, ".forklift.yaml", "hooks:\n  post-merge: ['exit 1']\n", "ci: break hooks")
+	os.Remove(log)
+	if err := Run(ctx, store, "org/repo", Options{}); !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Run() error = %v, want rolled back merge", err)
+	}
+	if data, _ = os.ReadFile(log); string(data) != "pre-merge dev\n" {
+		t.Errorf("hook log = %q, want the pre-merge hook of dev", data)
+	}
+
+	// NoHooks skips even failing hooks
+	if err := Run(ctx, store, "org/repo", Options{NoHooks: true}); err != nil {
+		t.Fatalf("Run() with NoHooks error = %v", err)
+	}
+	if subject := gitRun(t, "log", "-1", "--format=%s", "origin/dev"); !strings.Contains(subject, "break hooks") {
+		t.Error("build with NoHooks did not push")
+	}
+}
@@ -0,0 +1,87 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/backend"
+	"forklift/internal/git"
+	"forklift/internal/notes"
+	"forklift/internal/structures"
+)
+
+// NotesNone turns off the release notes printed after a build.
+const NotesNone = "none"
+
+// ReleaseNotes generates the notes of tag from the commits since prevTag.
+// Tags missing locally are fetched from origin first.
+func ReleaseNotes(g *git.Runner, tag, prevTag string) (*notes.Notes, error) {
+	from := ""
+	for _, t := range []string{tag, prevTag} {
+		if t == "" || g.TagExists(t) {
+			continue
+		}
+		if err := g.FetchTags("origin", t); err != nil {
+			return nil, fmt.Errorf("tag %s not found locally or on origin: %w", t, err)
+		}
+	}
+	if prevTag != "" {
+		from = "refs/tags/" + prevTag
+	}
+	commits, err := g.Commits(from, "refs/tags/"+tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits of %s: %w", tag, err)
+	}
+	return notes.Generate(tag, prevTag, commits), nil
+}
+
+// PreviousTag returns the tag built before tag: the previous successful build
+// of the same merge branch in the tag history, or else the closest earlier
+// tag in git. It returns "" for the first tag.
+func PreviousTag(ctx context.Context, store backend.Store, repoName, tag string) (string, error) {
+	history, err := store.ListHistory(ctx, repoName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tag history: %w", err)
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		rec := history[i]
+		if rec.Tag != tag || rec.Outcome != structures.OutcomeSuccess {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			prev := history[j]
+			if prev.MergeBranch == rec.MergeBranch && prev.Outcome == structures.OutcomeSuccess && prev.Tag != "" && prev.Tag != tag {
+				return prev.Tag, nil
+			}
+		}
+		break
+	}
+
+	if !git.TagExists(tag) {
+		if err := git.FetchTags("origin", tag); err != nil {
+			return "", fmt.Errorf("tag %s not found locally or on origin: %w", tag, err)
+		}
+	}
+	prev, err := git.PreviousTag(tag)
+	if err != nil {
+		// No earlier tag
+		return "", nil
+	}
+	return prev, nil
+}
+
+// printNotes prints the release notes of the tag the build just pushed.
+// Failing to generate them never fails the build.
+func printNotes(state *structures.BuildState, opts Options) {
+	if opts.Notes == NotesNone || state.Tag == "" {
+		return
+	}
+	n, err := ReleaseNotes(gitFor(*state), state.Tag, state.PrevTag)
+	if err == nil {
+		var out string
+		if out, err = n.Render(opts.Notes); err == nil {
+			fmt.Printf("\n📝 Release notes:\n\n%s", out)
+			return
+		}
+	}
+	fmt.Printf("Warning: failed to generate release notes: %v\n", err)
+}
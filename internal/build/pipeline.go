@@ -0,0 +1,360 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forklift/internal/backend"
+	"forklift/internal/git"
+	"forklift/internal/hooks"
+	"forklift/internal/repoconfig"
+	"forklift/internal/structures"
+	"forklift/internal/tagscheme"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Build steps, in the order they run. Each step is journaled in the build
+// state once it succeeds, so a resumed build picks up at the first step that
+// has not completed yet instead of starting over.
+const (
+	StepMerge         = "merge"
+	StepPushBranch    = "push-branch"
+	StepTag           = "tag"
+	StepPushTag       = "push-tag"
+	StepUpdateBackend = "update-backend"
+	StepRelease       = "release"
+)
+
+// Steps lists the build steps in the order they run. StepRelease only runs
+// when publishing releases is turned on.
+var Steps = []string{StepMerge, StepPushBranch, StepTag, StepPushTag, StepUpdateBackend, StepRelease}
+
+// NextStep returns the first step state has not completed, or "" if all have.
+func NextStep(state structures.BuildState) string {
+	for _, step := range Steps {
+		if !stepDone(state, step) {
+			return step
+		}
+	}
+	return ""
+}
+
+func stepDone(state structures.BuildState, step string) bool {
+	return slices.Contains(state.Steps, step)
+}
+
+// completeStep journals step as done. Failing to save is only a warning: the
+// worst case is that a resumed build repeats the step.
+func completeStep(state *structures.BuildState, step string) {
+	if !stepDone(*state, step) {
+		state.Steps = append(state.Steps, step)
+	}
+	if err := SaveState(*state); err != nil {
+		fmt.Printf("Warning: failed to save build state: %v\n", err)
+	}
+}
+
+// undoStep removes step from the journal, so it runs again.
+func undoStep(state *structures.BuildState, step string) {
+	state.Steps = slices.DeleteFunc(state.Steps, func(s string) bool { return s == step })
+	if err := SaveState(*state); err != nil {
+		fmt.Printf("Warning: failed to save build state: %v\n", err)
+	}
+}
+
+// finish runs the steps after the merge that state has not completed yet and
+// returns the tag of the build.
+func finish(ctx context.Context, store backend.Store, state *structures.BuildState, lastTag string, opts Options) (string, error) {
+	g := gitFor(*state)
+
+	// 5. Check the merged code before anything is pushed; undo the merge if the checks fail
+	if !stepDone(*state, StepPushBranch) {
+		for _, phase := range []string{hooks.PostMerge, hooks.PrePush} {
+			if err := runHooks(*state, phase, opts, nil); err != nil {
+				return state.Tag, &rollbackError{err}
+			}
+		}
+	}
+
+	// 6. Take the build lock so concurrent builds of this repo don't compute the same tag
+	owner := lockOwner()
+	fmt.Println("🔒 Acquiring build lock...")
+	if _, err := store.AcquireLock(ctx, state.RepoName, owner, lockTTL, opts.StealLock); err != nil {
+		if errors.Is(err, structures.ErrLockHeld) {
+			return state.Tag, fmt.Errorf("%w. Wait for that build, or rerun with --steal-lock if it is dead", err)
+		}
+		return state.Tag, fmt.Errorf("failed to acquire build lock: %w", err)
+	}
+	defer func() {
+		if err := store.ReleaseLock(ctx, state.RepoName, owner); err != nil {
+			fmt.Printf("Warning: failed to release build lock: %v\n", err)
+		}
+	}()
+
+	// 7. Push Merge Branch (Commit)
+	if !stepDone(*state, StepPushBranch) {
+		fmt.Println("📤 Pushing merge commit...")
+		if err := g.PushBranch("origin", state.MergeBranch); err != nil {
+			return state.Tag, fmt.Errorf("failed to push branch %s: %w", state.MergeBranch, err)
+		}
+		completeStep(state, StepPushBranch)
+	}
+
+	// 8. Determine, Create and Push Tag. Retried when someone else claims the tag first.
+	taken := make(map[string]bool)
+	for attempt := 1; !stepDone(*state, StepPushTag); attempt++ {
+		if attempt > maxTagAttempts {
+			return state.Tag, fmt.Errorf("gave up claiming a new tag after %d attempts", maxTagAttempts)
+		}
+
+		if stepDone(*state, StepTag) {
+			fmt.Printf("🏷️  Reusing tag %s chosen earlier\n", state.Tag)
+		} else if err := createTag(ctx, store, g, state, lastTag, taken, opts); err != nil {
+			return state.Tag, err
+		}
+
+		fmt.Println("🚀 Pushing tag...")
+		if err := g.PushTag("origin", state.Tag); err != nil {
+			if g.RemoteTagExists("origin", state.Tag) {
+				fmt.Printf("Tag %s was pushed by someone else in the meantime, retrying...\n", state.Tag)
+				g.DeleteTag(state.Tag)
+				taken[state.Tag] = true
+				lastTag = state.BaseTag
+				state.Tag = ""
+				undoStep(state, StepTag)
+				continue
+			}
+			return state.Tag, fmt.Errorf("failed to push tag %s: %w", state.Tag, err)
+		}
+		completeStep(state, StepPushTag)
+	}
+
+	// 9. Update Backend, only if it still holds the tag we computed from
+	if !stepDone(*state, StepUpdateBackend) {
+		// Pushing may have outlasted the lock; renew it so no other build records a tag meanwhile
+		if _, err := store.AcquireLock(ctx, state.RepoName, owner, lockTTL, false); err != nil {
+			return state.Tag, fmt.Errorf("tag %s was pushed, but the build lock was lost before updating the backend: %w", state.Tag, err)
+		}
+		fmt.Println("📊 Updating backend...")
+		if err := updateBackend(ctx, store, state, opts); err != nil {
+			return state.Tag, err
+		}
+		completeStep(state, StepUpdateBackend)
+	}
+
+	// 10. Publish GitHub Release
+	if opts.Release != nil && !stepDone(*state, StepRelease) {
+		if err := publishRelease(ctx, state, opts.Release); err != nil {
+			return state.Tag, fmt.Errorf("tag %s was pushed, but failed to publish its GitHub release: %w", state.Tag, err)
+		}
+		completeStep(state, StepRelease)
+	}
+
+	return state.Tag, nil
+}
+
+// updateBackend records state.Tag as the latest tag of the repo, provided
+// the backend still holds the tag the build computed from. If another build
+// recorded an older tag in the meantime, the update is retried against that
+// tag; if the backend already holds a tag that is not older, the build stops.
+func updateBackend(ctx context.Context, store backend.Store, state *structures.BuildState, opts Options) error {
+	expected := state.BaseTag
+	for attempt := 1; ; attempt++ {
+		err := store.UpdateRepoTag(ctx, state.RowIdx, expected, state.Tag)
+		if err == nil {
+			return nil
+		}
+		info, getErr := store.GetRepoInfo(ctx, state.RepoName)
+		if !errors.Is(err, structures.ErrTagConflict) || getErr != nil || info == nil {
+			return fmt.Errorf("tag %s was pushed, but failed to update backend: %w", state.Tag, err)
+		}
+		// A previous run may have updated the backend without journaling it
+		if info.LatestTag == state.Tag {
+			return nil
+		}
+
+		tmpl, tmplErr := tagTemplate(opts)
+		if tmplErr != nil {
+			return tmplErr
+		}
+		if !tmpl.Newer(state.Tag, info.LatestTag) || attempt >= maxTagAttempts {
+			return fmt.Errorf("tag %s was pushed, but the backend now holds %s: %w. Run 'forklift tag doctor --fix' to reconcile them", state.Tag, info.LatestTag, err)
+		}
+		fmt.Printf("🔁 Backend tag changed from %q to %q, retrying the update...\n", expected, info.LatestTag)
+		expected = info.LatestTag
+	}
+}
+
+// createTag picks the next free tag after the latest one in the backend,
+// creates it locally and journals it in state.
+func createTag(ctx context.Context, store backend.Store, g *git.Runner, state *structures.BuildState, lastTag string, taken map[string]bool, opts Options) error {
+	// Re-check that the backend still holds the tag we read
+	info, err := store.GetRepoInfo(ctx, state.RepoName)
+	if err != nil {
+		return fmt.Errorf("failed to get repo info: %w", err)
+	}
+	if info == nil {
+		return fmt.Errorf("repo %s not found in backend", state.RepoName)
+	}
+	if info.LatestTag != lastTag {
+		fmt.Printf("🔁 Latest tag changed from %q to %q, recomputing...\n", lastTag, info.LatestTag)
+		lastTag = info.LatestTag
+	}
+
+	tmpl, err := tagTemplate(opts)
+	if err != nil {
+		return err
+	}
+	sha, err := g.HeadCommit()
+	if err != nil {
+		return fmt.Errorf("failed to get merge commit: %w", err)
+	}
+	b := tagscheme.Build{Branch: state.MergeBranch, SHA: sha, Time: time.Now()}
+
+	// Continue from the remote's highest tag if the backend is behind it, e.g.
+	// because someone tagged without forklift or a backend update failed
+	from := lastTag
+	if remote, err := g.RemoteTags("origin"); err != nil {
+		fmt.Printf("Warning: failed to list remote tags, relying on the backend: %v\n", err)
+	} else if highest := tmpl.Highest(slices.Collect(maps.Keys(remote)), state.MergeBranch); tmpl.Newer(highest, from) {
+		fmt.Printf("🔁 Remote has tag %s, newer than the backend's %q; continuing from it\n", highest, from)
+		from = highest
+	}
+	if err := checkSequence(tmpl, from, opts); err != nil {
+		return err
+	}
+
+	newTag, err := nextFreeTag(g, tmpl, from, b, tagscheme.Bump{Level: state.Bump, Pre: state.Pre}, taken)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🏷️  New tag: %s\n", newTag)
+
+	if err := writeTag(g, state, newTag, from, sha, opts); err != nil {
+		return err
+	}
+	state.Tag = newTag
+	state.BaseTag = lastTag
+	state.PrevTag = from
+	completeStep(state, StepTag)
+	return nil
+}
+
+// writeTag creates newTag of the configured type. Signed tags are verified
+// before they can be pushed, and deleted again if verification fails.
+func writeTag(g *git.Runner, state *structures.BuildState, newTag, prevTag, sha string, opts Options) error {
+	if opts.TagType == "" || opts.TagType == TagLightweight {
+		fmt.Println("🏷️  Creating tag...")
+		if err := g.Tag(newTag); err != nil {
+			return fmt.Errorf("failed to create tag %s: %w", newTag, err)
+		}
+		return nil
+	}
+
+	sign := opts.TagType == TagSigned
+	if sign {
+		fmt.Println("🔏 Creating signed tag...")
+	} else {
+		fmt.Println("🏷️  Creating annotated tag...")
+	}
+	message := tagMessage(g, state, newTag, prevTag, sha)
+	if err := g.TagWithMessage(newTag, message, sign); err != nil {
+		return fmt.Errorf("failed to create tag %s: %w", newTag, err)
+	}
+	if sign {
+		fmt.Println("🔍 Verifying tag signature...")
+		if err := g.VerifyTag(newTag); err != nil {
+			g.DeleteTag(newTag)
+			return fmt.Errorf("signature of tag %s could not be verified, tag deleted: %w", newTag, err)
+		}
+	}
+	return nil
+}
+
+// maxTagMessageCommits bounds the commit list in generated tag messages.
+const maxTagMessageCommits = 100
+
+// tagMessage describes the build in the message of an annotated tag: what
+// was merged where, by whom, and the commits since the previous tag.
+func tagMessage(g *git.Runner, state *structures.BuildState, newTag, prevTag, sha string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", newTag)
+	fmt.Fprintf(&b, "Merged %s into %s\n", state.OriginalBranch, state.MergeBranch)
+	fmt.Fprintf(&b, "Merge commit: %s\n", sha)
+	fmt.Fprintf(&b, "Tagged by: %s\n", git.UserIdentity())
+
+	if prevTag == "" {
+		return b.String()
+	}
+	if !g.TagExists(prevTag) {
+		g.FetchTags("origin", prevTag)
+	}
+	commits, err := g.Log("refs/tags/"+prevTag, sha)
+	if err != nil {
+		fmt.Fprintf(&b, "\nPrevious tag %s not found, commits omitted.\n", prevTag)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\nCommits since %s:\n", prevTag)
+	for i, c := range commits {
+		if i == maxTagMessageCommits {
+			fmt.Fprintf(&b, "... and %d more\n", len(commits)-i)
+			break
+		}
+		fmt.Fprintf(&b, "- %s\n", c)
+	}
+	if len(commits) == 0 {
+		b.WriteString("(none)\n")
+	}
+	return b.String()
+}
+
+// checkSequence refuses to continue from a latest tag that doesn't match
+// tmpl, as that would silently restart the tag sequence, unless
+// opts.NewSequence asks for it.
+func checkSequence(tmpl *tagscheme.Template, latest string, opts Options) error {
+	if latest == "" {
+		return nil
+	}
+	if _, ok := tmpl.Match(latest); ok {
+		return nil
+	}
+	if !opts.NewSequence {
+		return fmt.Errorf("latest tag %s does not match tag template %s. Set a matching tag template (tag_template in %s or the repo's Tag Template in the backend), or rerun with --new-sequence to start a new sequence", latest, tmpl, repoconfig.FileName)
+	}
+	fmt.Printf("Latest tag %s does not match tag template %s, starting a new sequence\n", latest, tmpl)
+	return nil
+}
+
+// tagTemplate parses the tag template of the build, tagscheme.Default if
+// none is set.
+func tagTemplate(opts Options) (*tagscheme.Template, error) {
+	raw := opts.TagTemplate
+	if raw == "" {
+		raw = tagscheme.Default
+	}
+	tmpl, err := tagscheme.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// nextFreeTag applies bump to lastTag, then keeps incrementing until it finds
+// a tag that exists neither locally nor in taken.
+func nextFreeTag(g *git.Runner, tmpl *tagscheme.Template, lastTag string, b tagscheme.Build, bump tagscheme.Bump, taken map[string]bool) (string, error) {
+	newTag, err := tmpl.Next(lastTag, b, bump)
+	if err != nil {
+		return "", err
+	}
+	for taken[newTag] || g.TagExists(newTag) {
+		fmt.Printf("Tag %s already exists, incrementing further...\n", newTag)
+		// Only the first step takes the bump level; further ones stay on the same release line
+		if newTag, err = tmpl.Next(newTag, b, tagscheme.Bump{Pre: bump.Pre}); err != nil {
+			return "", err
+		}
+	}
+	return newTag, nil
+}
@@ -0,0 +1,423 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"forklift/internal/backend"
+	"forklift/internal/filestore"
+	"forklift/internal/git"
+	"forklift/internal/github"
+	"forklift/internal/structures"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyStore fails the first UpdateRepoTag after applying it, like a backend
+// write whose response got lost.
+type flakyStore struct {
+	backend.Store
+	failed bool
+}
+
+func (s *flakyStore) UpdateRepoTag(ctx context.Context, rowIdx int, expectedTag, tag string) error {
+	if err := s.Store.UpdateRepoTag(ctx, rowIdx, expectedTag, tag); err != nil {
+		return err
+	}
+	if !s.failed {
+		s.failed = true
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+// racingStore plays another build: right before the first UpdateRepoTag it
+// records tag itself, and with stealLock it takes over the build lock when
+// the build renews it.
+type racingStore struct {
+	backend.Store
+	tag       string
+	stealLock bool
+	acquired  int
+}
+
+func (s *racingStore) UpdateRepoTag(ctx context.Context, rowIdx int, expectedTag, tag string) error {
+	if s.tag != "" {
+		info, _ := s.Store.GetRepoInfo(ctx, "org/repo")
+		if err := s.Store.UpdateRepoTag(ctx, rowIdx, info.LatestTag, s.tag); err != nil {
+			return err
+		}
+		s.tag = ""
+	}
+	return s.Store.UpdateRepoTag(ctx, rowIdx, expectedTag, tag)
+}
+
+func (s *racingStore) AcquireLock(ctx context.Context, repo, owner string, ttl time.Duration, steal bool) (*structures.Lock, error) {
+	if s.acquired++; s.acquired == 2 && s.stealLock {
+		if _, err := s.Store.AcquireLock(ctx, repo, "other build", ttl, true); err != nil {
+			return nil, err
+		}
+	}
+	return s.Store.AcquireLock(ctx, repo, owner, ttl, steal)
+}
+
+// newTestRepo creates a clone of a bare origin with a dev merge branch and a
+// feature branch checked out, and makes it the current directory. It returns
+// the path of the global git config the repo uses.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	gitconfig := filepath.Join(t.TempDir(), "gitconfig")
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	origin := filepath.Join(dir, "origin.git")
+	work := filepath.Join(dir, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		if _, err := (&git.Runner{Dir: dir}).Run(args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(dir, "config", "--global", "user.name", "Test")
+	run(dir, "config", "--global", "user.email", "test@example.com")
+	run(dir, "init", "-q", "--bare", origin)
+	run(dir, "clone", "-q", origin, work)
+	run(work, "commit", "-q", "--allow-empty", "-m", "init")
+	run(work, "branch", "-M", "dev")
+	run(work, "push", "-q", "origin", "dev")
+	run(work, "checkout", "-q", "-b", "feature")
+	run(work, "commit", "-q", "--allow-empty", "-m", "feat: add widgets")
+	t.Chdir(work)
+	return gitconfig
+}
+
+func newTestStore(t *testing.T) backend.Store {
+	t.Helper()
+	fs, err := filestore.New(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SetMergeBranch(context.Background(), "org/repo", "dev", 0); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestResumeReusesPushedTag(t *testing.T) {
+	newTestRepo(t)
+	ctx := context.Background()
+	store := &flakyStore{Store: newTestStore(t)}
+
+	if err := Run(ctx, store, "org/repo", Options{}); err == nil {
+		t.Fatal("Run() succeeded, want backend update failure")
+	}
+	state, err := LoadState()
+	if err != nil || state == nil {
+		t.Fatalf("LoadState() = %v, %v; want saved state", state, err)
+	}
+	if state.Tag != "v-dev-0.0.1" || NextStep(*state) != StepUpdateBackend {
+		t.Fatalf("state = %+v, want tag v-dev-0.0.1 and next step %s", state, StepUpdateBackend)
+	}
+
+	if err := Run(ctx, store, "org/repo", Options{}); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+	if state, _ := LoadState(); state != nil {
+		t.Errorf("state still saved after success: %+v", state)
+	}
+	info, _ := store.GetRepoInfo(ctx, "org/repo")
+	if info.LatestTag != "v-dev-0.0.1" {
+		t.Errorf("LatestTag = %q, want v-dev-0.0.1", info.LatestTag)
+	}
+	if git.TagExists("v-dev-0.0.2") {
+		t.Error("resume created an orphan tag v-dev-0.0.2")
+	}
+	if branch, _ := git.CurrentBranch(); branch != "feature" {
+		t.Errorf("current branch = %s, want feature", branch)
+	}
+
+	history, _ := store.ListHistory(ctx, "org/repo")
+	var outcomes []string
+	for _, rec := range history {
+		outcomes = append(outcomes, rec.Outcome)
+	}
+	if want := []string{"failed", "success"}; !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("history outcomes = %v, want %v", outcomes, want)
+	}
+}
+
+func TestBackendChangedAfterPush(t *testing.T) {
+	newTestRepo(t)
+	ctx := context.Background()
+	store := &racingStore{Store: newTestStore(t)}
+	if err := store.Store.UpdateRepoTag(ctx, 0, "", "v-dev-0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	// Another build pushed v-dev-0.0.2 and records it while we push v-dev-0.0.3
+	gitRun(t, "tag", "v-dev-0.0.2", "dev")
+	gitRun(t, "push", "-q", "origin", "v-dev-0.0.2")
+	store.tag = "v-dev-0.0.2"
+
+	if err := Run(ctx, store, "org/repo", Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if info, _ := store.GetRepoInfo(ctx, "org/repo"); info.LatestTag != "v-dev-0.0.3" {
+		t.Errorf("LatestTag = %q, want v-dev-0.0.3", info.LatestTag)
+	}
+
+	// A newer tag in the backend is not overwritten
+	gitRun(t, "commit", "-q", "--allow-empty", "-m", "fix: second change")
+	store.tag = "v-dev-0.0.9"
+	err := Run(ctx, store, "org/repo", Options{})
+	if err == nil || !strings.Contains(err.Error(), "v-dev-0.0.9") || !strings.Contains(err.Error(), "tag doctor --fix") {
+		t.Fatalf("Run() error = %v, want conflict with v-dev-0.0.9", err)
+	}
+	if info, _ := store.GetRepoInfo(ctx, "org/repo"); info.LatestTag != "v-dev-0.0.9" {
+		t.Errorf("LatestTag = %q, want v-dev-0.0.9", info.LatestTag)
+	}
+	if state, _ := LoadState(); state == nil || NextStep(*state) != StepUpdateBackend {
+		t.Errorf("state = %+v, want the build kept at %s", state, StepUpdateBackend)
+	}
+	Abort()
+}
+
+func TestLockLostBeforeBackendUpdate(t *testing.T) {
+	newTestRepo(t)
+	ctx := context.Background()
+	store := &racingStore{Store: newTestStore(t), stealLock: true}
+
+	err := Run(ctx, store, "org/repo", Options{})
+	if !errors.Is(err, structures.ErrLockHeld) {
+		t.Fatalf("Run() error = %v, want lost lock", err)
+	}
+	if info, _ := store.GetRepoInfo(ctx, "org/repo"); info.LatestTag != "" {
+		t.Errorf("LatestTag = %q, want the backend left alone", info.LatestTag)
+	}
+}
+
+func TestAbortResetsUnpushedMerge(t *testing.T) {
+	for _, worktree := range []bool{false, true} {
+		t.Run(map[bool]string{false: "checkout", true: "worktree"}[worktree], func(t *testing.T) {
+			newTestRepo(t)
+			ctx := context.Background()
+			store := newTestStore(t)
+			gitRun(t, "remote", "set-url", "--push", "origin", filepath.Join(t.TempDir(), "missing.git"))
+
+			err := Run(ctx, store, "org/repo", Options{Worktree: worktree})
+			if err == nil || !strings.Contains(err.Error(), "failed to push branch") {
+				t.Fatalf("Run() error = %v, want push failure", err)
+			}
+			if err := Abort(); err != nil {
+				t.Fatalf("Abort() error = %v", err)
+			}
+			if dev, origin := gitRun(t, "rev-parse", "dev"), gitRun(t, "rev-parse", "origin/dev"); dev != origin {
+				t.Errorf("dev = %.7s after abort, want origin/dev %.7s", dev, origin)
+			}
+			if branch, _ := git.CurrentBranch(); branch != "feature" {
+				t.Errorf("current branch = %s, want feature", branch)
+			}
+		})
+	}
+}
+
+func TestNonMatchingLatestTag(t *testing.T) {
+	newTestRepo(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+	if err := store.UpdateRepoTag(ctx, 0, "", "build-17"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Run(ctx, store, "org/repo", Options{})
+	if err == nil || !strings.Contains(err.Error(), "build-17") || !strings.Contains(err.Error(), "--new-sequence") {
+		t.Fatalf("Run() error = %v, want a refusal to restart the sequence", err)
+	}
+	if state, _ := LoadState(); state != nil {
+		t.Errorf("state saved after refusing: %+v", state)
+	}
+	if out, _ := exec.Command("git", "rev-list", "--count", "origin/dev").Output(); strings.TrimSpace(string(out)) != "1" {
+		t.Errorf("origin/dev has %s commits, want it left alone", out)
+	}
+
+	if err := Run(ctx, store, "org/repo", Options{NewSequence: true}); err != nil {
+		t.Fatalf("Run() with NewSequence error = %v", err)
+	}
+	if info, _ := store.GetRepoInfo(ctx, "org/repo"); info.LatestTag != "v-dev-0.0.1" {
+		t.Errorf("LatestTag = %q, want v-dev-0.0.1", info.LatestTag)
+	}
+}
+
+func TestAnnotatedTag(t *testing.T) {
+	newTestRepo(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for i, want := range []string{"v-dev-0.0.1", "v-dev-0.0.2"} {
+		if i > 0 {
+			if _, err := git.Default.Run("commit", "-q", "--allow-empty", "-m", "fix: second change"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := Run(ctx, store, "org/repo", Options{TagType: TagAnnotated}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if kind, _ := git.Default.Run("cat-file", "-t", want); kind != "tag" {
+			t.Fatalf("%s is a %q object, want an annotated tag", want, kind)
+		}
+	}
+
+	message, err := git.Default.Run("tag", "-l", "--format=%(contents)", "v-dev-0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Merged feature into dev", "Merge commit: ", "Tagged by: Test <test@example.com>", "Commits since v-dev-0.0.1:", "fix: second change"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("tag message %q does not contain %q", message, want)
+		}
+	}
+}
+
+func TestSignedTag(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+	gitconfig := newTestRepo(t)
+	key := filepath.Join(t.TempDir(), "key")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v: %s", err, out)
+	}
+	cfg := &git.Runner{Env: []string{"GIT_CONFIG_GLOBAL=" + gitconfig}}
+	for _, kv := range [][2]string{{"gpg.format", "ssh"}, {"user.signingkey", key + ".pub"}} {
+		if _, err := cfg.Run("config", "--global", kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	// Without allowed signers the signature can't be verified, so the tag must not be pushed
+	if err := Run(ctx, store, "org/repo", Options{TagType: TagSigned}); err == nil {
+		t.Fatal("Run() succeeded without a way to verify the signature")
+	}
+	if git.TagExists("v-dev-0.0.1") || git.RemoteTagExists("origin", "v-dev-0.0.1") {
+		t.Fatal("unverified tag was kept")
+	}
+	if err := Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	signers := filepath.Join(t.TempDir(), "allowed_signers")
+	pub, _ := os.ReadFile(key + ".pub")
+	if err := os.WriteFile(signers, append([]byte("test@example.com "), pub...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Run("config", "--global", "gpg.ssh.allowedSignersFile", signers); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(ctx, store, "org/repo", Options{TagType: TagSigned}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !git.RemoteTagExists("origin", "v-dev-0.0.1") {
+		t.Error("signed tag was not pushed")
+	}
+}
+
+func TestReleaseAssetsCheckedBeforeMerge(t *testing.T) {
+	newTestRepo(t)
+	store := newTestStore(t)
+	for _, pattern := range []string{"dist/*.tar.gz", "[bad"} {
+		opts := Options{Release: &ReleaseOptions{Token: "secret", Assets: []string{pattern}}}
+		if err := Run(context.Background(), store, "org/repo", opts); err == nil || !strings.Contains(err.Error(), pattern) {
+			t.Errorf("Run() with asset %q error = %v, want the pattern rejected", pattern, err)
+		}
+	}
+	if state, _ := LoadState(); state != nil {
+		t.Errorf("state saved after rejecting the assets: %+v", state)
+	}
+	if dev, origin := gitRun(t, "rev-parse", "dev"), gitRun(t, "rev-parse", "origin/dev"); dev != origin {
+		t.Error("dev was merged despite the bad assets")
+	}
+}
+
+func TestRelease(t *testing.T) {
+	newTestRepo(t)
+	if err := os.WriteFile("app.tar.gz", []byte("build"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	var created []github.ReleaseRequest
+	var uploads []string
+	failUpload := true
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release := func() github.Release {
+			r := github.Release{TagName: created[0].TagName, Prerelease: created[0].Prerelease, UploadURL: srv.URL + "/uploads{?name,label}"}
+			for _, name := range uploads {
+				r.Assets = append(r.Assets, github.Asset{Name: name})
+			}
+			return r
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/repo/releases/tags/v-dev-0.0.1":
+			if len(created) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(release())
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/org/repo/releases":
+			var req github.ReleaseRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			created = append(created, req)
+			json.NewEncoder(w).Encode(release())
+		case r.Method == http.MethodPost && r.URL.Path == "/uploads":
+			if failUpload {
+				failUpload = false
+				http.Error(w, "upload failed", http.StatusBadGateway)
+				return
+			}
+			uploads = append(uploads, r.URL.Query().Get("name"))
+			json.NewEncoder(w).Encode(github.Asset{Name: r.URL.Query().Get("name")})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	opts := Options{Release: &ReleaseOptions{Token: "secret", APIURL: srv.URL, Assets: []string{"*.tar.gz"}}}
+	if err := Run(ctx, store, "org/repo", opts); err == nil {
+		t.Fatal("Run() succeeded, want asset upload failure")
+	}
+	if state, _ := LoadState(); state == nil || NextStep(*state) != StepRelease {
+		t.Fatalf("state = %+v, want next step %s", state, StepRelease)
+	}
+	if err := Run(ctx, store, "org/repo", opts); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+
+	if len(created) != 1 {
+		t.Fatalf("created %d releases, want 1", len(created))
+	}
+	if req := created[0]; req.TagName != "v-dev-0.0.1" || !req.Prerelease || !strings.Contains(req.Body, "add widgets") {
+		t.Errorf("release request = %+v, want a pre-release of v-dev-0.0.1 with notes", req)
+	}
+	if want := []string{"app.tar.gz"}; !reflect.DeepEqual(uploads, want) {
+		t.Errorf("uploads = %v, want %v", uploads, want)
+	}
+}
@@ -0,0 +1,119 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"forklift/internal/github"
+	"forklift/internal/structures"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultProdBranches are the merge branches whose releases are not marked
+// as pre-releases, unless configured otherwise.
+var DefaultProdBranches = []string{"main", "master", "prod", "production"}
+
+// ReleaseOptions controls the GitHub release published for each new tag.
+type ReleaseOptions struct {
+	// Token is the GitHub token; it needs write access to the repository.
+	Token string
+	// APIURL is the GitHub API root. Empty means github.DefaultBaseURL.
+	APIURL string
+	// Assets are files, or glob patterns, to attach to the release.
+	Assets []string
+	// ProdBranches are the merge branches that publish full releases; the
+	// rest publish pre-releases. Empty means DefaultProdBranches.
+	ProdBranches []string
+}
+
+// Prerelease reports whether a release of a tag built on mergeBranch is a
+// pre-release: the branch is not a prod branch, or the tag is a pre-release.
+func (o *ReleaseOptions) Prerelease(mergeBranch, pre string) bool {
+	prod := o.ProdBranches
+	if len(prod) == 0 {
+		prod = DefaultProdBranches
+	}
+	return pre != "" || !slices.Contains(prod, mergeBranch)
+}
+
+// publishRelease creates the GitHub release of state.Tag with its release
+// notes and uploads the assets. A release or asset that already exists, e.g.
+// from an earlier attempt of the build, is left as it is.
+func publishRelease(ctx context.Context, state *structures.BuildState, opts *ReleaseOptions) error {
+	owner, repo, ok := strings.Cut(state.RepoName, "/")
+	if !ok {
+		return fmt.Errorf("invalid repo format: %s (expected org/repo)", state.RepoName)
+	}
+	assets, err := expandAssets(opts.Assets)
+	if err != nil {
+		return err
+	}
+	client := github.NewClient(opts.Token, owner, repo).WithBaseURL(opts.APIURL)
+
+	release, err := client.GetReleaseByTag(ctx, state.Tag)
+	if err != nil {
+		return err
+	}
+	if release != nil {
+		fmt.Printf("📦 Release %s already exists\n", state.Tag)
+	} else {
+		fmt.Println("📦 Publishing GitHub release...")
+		body := ""
+		if n, err := ReleaseNotes(gitFor(*state), state.Tag, state.PrevTag); err != nil {
+			fmt.Printf("Warning: failed to generate release notes, publishing without them: %v\n", err)
+		} else {
+			body = n.Markdown()
+		}
+		release, err = client.CreateRelease(ctx, github.ReleaseRequest{
+			TagName:    state.Tag,
+			Name:       state.Tag,
+			Body:       body,
+			Prerelease: opts.Prerelease(state.MergeBranch, state.Pre),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range assets {
+		name := filepath.Base(path)
+		if slices.ContainsFunc(release.Assets, func(a github.Asset) bool { return a.Name == name }) {
+			fmt.Printf("📎 Asset %s already uploaded\n", name)
+			continue
+		}
+		fmt.Printf("📎 Uploading %s...\n", name)
+		if _, err := client.UploadAsset(ctx, release, path); err != nil {
+			return err
+		}
+	}
+
+	kind := "Release"
+	if release.Prerelease {
+		kind = "Pre-release"
+	}
+	fmt.Printf("🚀 %s published: %s\n", kind, release.HTMLURL)
+	return nil
+}
+
+// expandAssets resolves the asset glob patterns to files. A pattern that
+// matches nothing is an error, so a missing build artifact is not silently
+// left out of the release.
+func expandAssets(patterns []string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match asset %q", pattern)
+		}
+		for _, m := range matches {
+			if !slices.Contains(paths, m) {
+				paths = append(paths, m)
+			}
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,147 @@
+package build
+
+import (
+	"fmt"
+	"forklift/internal/git"
+	"forklift/internal/structures"
+	"strings"
+)
+
+// Merge strategies.
+const (
+	// StrategyMerge creates a merge commit (the default).
+	StrategyMerge = "merge"
+	// StrategySquash commits all changes of the branch as one commit.
+	StrategySquash = "squash"
+	// StrategyRebase rebases a copy of the branch onto the merge branch and
+	// fast-forwards the merge branch to it, keeping history linear.
+	StrategyRebase = "rebase"
+	// StrategyFastForward only fast-forwards; it fails if the branch is not
+	// up to date with the merge branch.
+	StrategyFastForward = "ff-only"
+)
+
+// rebaseBranch is the temporary branch the rebase strategy rebases, so the
+// developer's own branch is never rewritten.
+const rebaseBranch = "forklift-rebase"
+
+// Strategy checks the merge strategy of a build and returns it,
+// StrategyMerge if none is set.
+func Strategy(opts Options) (string, error) {
+	switch strategy := strings.TrimSpace(opts.Strategy); strategy {
+	case "":
+		return StrategyMerge, nil
+	case StrategyMerge, StrategySquash, StrategyRebase, StrategyFastForward:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy %q, want %s, %s, %s or %s", strategy, StrategyMerge, StrategySquash, StrategyRebase, StrategyFastForward)
+	}
+}
+
+// mergeBranch merges the original branch into the merge branch, which must be
+// checked out, with the strategy of the build. Check mergeInProgress after an
+// error to tell conflicts from other failures.
+func mergeBranch(g *git.Runner, state structures.BuildState) error {
+	switch state.Strategy {
+	case StrategySquash:
+		message := squashMessage(g, state)
+		if err := g.MergeSquash(state.OriginalBranch); err != nil {
+			// Let 'git commit' pick up our message once the conflicts are resolved
+			if g.IsSquashInProgress() {
+				g.WriteSquashMessage(message)
+			}
+			return err
+		}
+		if !g.HasStagedChanges() {
+			fmt.Println("Nothing to squash, the merge branch already has all changes.")
+			return nil
+		}
+		return g.CommitStaged(message)
+
+	case StrategyRebase:
+		if err := g.CheckoutNew(rebaseBranch, state.OriginalBranch); err != nil {
+			return err
+		}
+		if err := g.Rebase(state.MergeBranch); err != nil {
+			return err
+		}
+		return finishRebase(g, state)
+
+	case StrategyFastForward:
+		if err := g.MergeFastForward(state.OriginalBranch); err != nil {
+			return fmt.Errorf("%s can't be fast-forwarded to %s; rebase it onto %s first or pick another strategy: %w", state.MergeBranch, state.OriginalBranch, state.MergeBranch, err)
+		}
+		return nil
+
+	default:
+		return g.Merge(state.OriginalBranch)
+	}
+}
+
+// finishRebase fast-forwards the merge branch to the rebased copy of the
+// original branch and deletes the copy.
+func finishRebase(g *git.Runner, state structures.BuildState) error {
+	if err := g.Checkout(state.MergeBranch); err != nil {
+		return err
+	}
+	if err := g.MergeFastForward(rebaseBranch); err != nil {
+		return err
+	}
+	if err := g.DeleteBranch(rebaseBranch); err != nil {
+		fmt.Printf("Warning: failed to delete branch %s: %v\n", rebaseBranch, err)
+	}
+	return nil
+}
+
+// mergeInProgress reports whether a merge, squash or rebase stopped halfway,
+// usually on conflicts.
+func mergeInProgress(g *git.Runner) bool {
+	return g.IsMergeInProgress() || g.IsSquashInProgress() || g.IsRebaseInProgress()
+}
+
+// abortMerge undoes whatever merge, squash or rebase is in progress.
+func abortMerge(g *git.Runner) error {
+	switch {
+	case g.IsRebaseInProgress():
+		fmt.Println("↩️  Aborting rebase...")
+		return g.AbortRebase()
+	case g.IsMergeInProgress():
+		fmt.Println("↩️  Aborting merge...")
+		return g.AbortMerge()
+	case g.IsSquashInProgress():
+		fmt.Println("↩️  Aborting squash...")
+		return g.AbortSquash()
+	}
+	return nil
+}
+
+// ConflictHelp tells how to finish the merge of a build that stopped on
+// conflicts with the given strategy.
+func ConflictHelp(strategy string) string {
+	if strategy == StrategyRebase {
+		return "Please resolve the conflicts, 'git add' them and run 'git rebase --continue' until the rebase is done"
+	}
+	return "Please resolve the conflicts manually and commit the changes"
+}
+
+// squashMessage describes a squash merge: the subject of the only commit, or
+// a generic subject for several, followed by the list of squashed commits.
+func squashMessage(g *git.Runner, state structures.BuildState) string {
+	commits, _ := g.Log("HEAD", state.OriginalBranch)
+	var b strings.Builder
+	if len(commits) == 1 {
+		_, subject, _ := strings.Cut(commits[0], " ")
+		fmt.Fprintf(&b, "%s\n\n", subject)
+	} else {
+		fmt.Fprintf(&b, "Squash merge branch '%s' into %s\n\n", state.OriginalBranch, state.MergeBranch)
+	}
+	fmt.Fprintf(&b, "Squashed %d commit(s) from %s:\n", len(commits), state.OriginalBranch)
+	for i, c := range commits {
+		if i == maxTagMessageCommits {
+			fmt.Fprintf(&b, "... and %d more\n", len(commits)-i)
+			break
+		}
+		fmt.Fprintf(&b, "- %s\n", c)
+	}
+	return b.String()
+}
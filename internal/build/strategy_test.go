@@ -0,0 +1,147 @@
+package build
+
+import (
+	"context"
+	"forklift/internal/git"
+	"os"
+	"strings"
+	"testing"
+)
+
+func gitRun(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := git.Default.Run(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func commitFile(t *testing.T, name, content, message string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, "add", name)
+	gitRun(t, "commit", "-q", "-m", message)
+}
+
+// divergeDev makes dev and feature change file.txt in conflicting ways.
+func divergeDev(t *testing.T) {
+	t.Helper()
+	gitRun(t, "checkout", "-q", "dev")
+	commitFile(t, "file.txt", "dev\n", "chore: dev change")
+	gitRun(t, "push", "-q", "origin", "dev")
+	gitRun(t, "checkout", "-q", "feature")
+	commitFile(t, "file.txt", "feature\n", "fix: feature change")
+}
+
+func TestSquashStrategy(t *testing.T) {
+	newTestRepo(t)
+	commitFile(t, "a.txt", "a\n", "fix: second change")
+	before := gitRun(t, "rev-parse", "dev")
+	store := newTestStore(t)
+
+	if err := Run(context.Background(), store, "org/repo", Options{Strategy: StrategySquash}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if parents := gitRun(t, "log", "-1", "--format=%P", "origin/dev"); parents != before {
+		t.Errorf("squash commit parents = %q, want only %s", parents, before)
+	}
+	message := gitRun(t, "log", "-1", "--format=%B", "origin/dev")
+	for _, want := range []string{"Squash merge branch 'feature' into dev", "Squashed 2 commit(s) from feature", "feat: add widgets", "fix: second change"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("squash message %q does not contain %q", message, want)
+		}
+	}
+}
+
+func TestSquashConflictResume(t *testing.T) {
+	newTestRepo(t)
+	divergeDev(t)
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if err := Run(ctx, store, "org/repo", Options{Strategy: StrategySquash}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !git.IsSquashInProgress() {
+		t.Fatal("no squash in progress after conflicts")
+	}
+	if err := Run(ctx, store, "org/repo", Options{}); err == nil || !strings.Contains(err.Error(), "still in progress") {
+		t.Fatalf("Run() with unresolved conflicts = %v, want in progress error", err)
+	}
+
+	if err := os.WriteFile("file.txt", []byte("resolved\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, "add", "file.txt")
+	gitRun(t, "commit", "-q", "--no-edit")
+	if err := Run(ctx, store, "org/repo", Options{}); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+	if subject := gitRun(t, "log", "-1", "--format=%s", "origin/dev"); subject != "Squash merge branch 'feature' into dev" {
+		t.Errorf("squash commit subject = %q, want the generated one", subject)
+	}
+}
+
+func TestRebaseConflictResume(t *testing.T) {
+	newTestRepo(t)
+	t.Setenv("GIT_EDITOR", "true")
+	divergeDev(t)
+	feature := gitRun(t, "rev-parse", "feature")
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if err := Run(ctx, store, "org/repo", Options{Strategy: StrategyRebase}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !git.IsRebaseInProgress() {
+		t.Fatal("no rebase in progress after conflicts")
+	}
+	if err := Run(ctx, store, "org/repo", Options{}); err == nil || !strings.Contains(err.Error(), "rebase is still in progress") {
+		t.Fatalf("Run() during rebase = %v, want rebase in progress error", err)
+	}
+
+	if err := os.WriteFile("file.txt", []byte("resolved\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, "add", "file.txt")
+	gitRun(t, "rebase", "--continue")
+	if err := Run(ctx, store, "org/repo", Options{}); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+
+	if merges := gitRun(t, "rev-list", "--merges", "origin/dev"); merges != "" {
+		t.Errorf("dev has merge commits %q, want linear history", merges)
+	}
+	if subject := gitRun(t, "log", "-1", "--format=%s", "origin/dev"); subject != "fix: feature change" {
+		t.Errorf("dev tip = %q, want the rebased feature commit", subject)
+	}
+	if got := gitRun(t, "rev-parse", "feature"); got != feature {
+		t.Error("rebase rewrote the developer's branch")
+	}
+	if git.Default.BranchExists(rebaseBranch) {
+		t.Errorf("temporary branch %s was left behind", rebaseBranch)
+	}
+	if branch, _ := git.CurrentBranch(); branch != "feature" {
+		t.Errorf("current branch = %s, want feature", branch)
+	}
+}
+
+func TestFastForwardOnly(t *testing.T) {
+	newTestRepo(t)
+	divergeDev(t)
+	store := newTestStore(t)
+
+	err := Run(context.Background(), store, "org/repo", Options{Strategy: StrategyFastForward})
+	if err == nil || !strings.Contains(err.Error(), "can't be fast-forwarded") {
+		t.Fatalf("Run() = %v, want fast-forward error", err)
+	}
+	if state, _ := LoadState(); state != nil {
+		t.Errorf("state kept after failed fast-forward: %+v", state)
+	}
+	if branch, _ := git.CurrentBranch(); branch != "feature" {
+		t.Errorf("current branch = %s, want feature", branch)
+	}
+}
@@ -8,7 +8,12 @@ import (
 	"path/filepath"
 )
 
-const DefaultSheetName = "merge_branches"
+const (
+	DefaultSheetName        = "merge_branches"
+	DefaultHistorySheetName = "merge_history"
+	DefaultLockSheetName    = "forklift_locks"
+	DefaultMetaSheetName    = "forklift_meta"
+)
 
 func Load() (structures.Config, error) {
 	cfgPath, err := Path()
@@ -0,0 +1,337 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"forklift/internal/git"
+	"forklift/internal/structures"
+)
+
+const (
+	// lockTimeout is how long a writer waits for another process to release the lock.
+	lockTimeout = 10 * time.Second
+	// staleLockAge is the age after which a lock file is assumed to belong to a
+	// crashed process. Holders refresh the lock file well before it gets this old.
+	staleLockAge = 30 * time.Second
+)
+
+// errMissingRow is returned when updating a repo entry that doesn't exist.
+var errMissingRow = errors.New("cannot update tag for non-existent repo row")
+
+// record mirrors a row of the merge_branches sheet.
+type record struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Time   string `json:"time"`
+	Tag    string `json:"tag"`
+	User   string `json:"user"`
+
+	Owner         string `json:"owner,omitempty"`
+	Environment   string `json:"environment,omitempty"`
+	Notes         string `json:"notes,omitempty"`
+	TagTemplate   string `json:"tag_template,omitempty"`
+	MergeStrategy string `json:"merge_strategy,omitempty"`
+}
+
+type document struct {
+	Repos   []record                   `json:"repos"`
+	History []structures.HistoryRecord `json:"history,omitempty"`
+	Locks   []structures.Lock          `json:"locks,omitempty"`
+}
+
+// Store keeps repository information in a JSON file on a local or shared filesystem.
+// Writers serialize on a lock file next to the data file.
+type Store struct {
+	path         string
+	lockTimeout  time.Duration
+	staleLockAge time.Duration
+}
+
+func New(path string) (*Store, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("state file must be an absolute path: %s", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return &Store{path: path, lockTimeout: lockTimeout, staleLockAge: staleLockAge}, nil
+}
+
+// GetRepoInfo returns a structures.RepoInfo struct for the given repository.
+// If the repository is not found, it returns nil and no error.
+func (s *Store) GetRepoInfo(ctx context.Context, repo string) (*structures.RepoInfo, error) {
+	doc, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range doc.Repos {
+		if r.Repo == repo {
+			return &structures.RepoInfo{
+				RowIdx:        i,
+				MergeBranch:   strings.TrimSpace(r.Branch),
+				LatestTag:     strings.TrimSpace(r.Tag),
+				LastUser:      strings.TrimSpace(r.User),
+				Owner:         r.Owner,
+				Environment:   r.Environment,
+				Notes:         r.Notes,
+				TagTemplate:   r.TagTemplate,
+				MergeStrategy: r.MergeStrategy,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// SetMergeBranch sets the merge branch for repo and clears its tag.
+// The entry is looked up by name while holding the lock, so rowIdx is ignored.
+func (s *Store) SetMergeBranch(ctx context.Context, repo, branch string, rowIdx int) error {
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	user := git.UserIdentity()
+
+	return s.update(ctx, func(doc *document) error {
+		for i := range doc.Repos {
+			if doc.Repos[i].Repo == repo {
+				// Clear the tag to start a new sequence, like the sheet backend does
+				doc.Repos[i].Branch = branch
+				doc.Repos[i].Time = timestamp
+				doc.Repos[i].Tag = ""
+				doc.Repos[i].User = user
+				return nil
+			}
+		}
+		doc.Repos = append(doc.Repos, record{Repo: repo, Branch: branch, Time: timestamp, User: user})
+		return nil
+	})
+}
+
+func (s *Store) UpdateRepoTag(ctx context.Context, rowIdx int, expectedTag, tag string) error {
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	user := git.UserIdentity()
+
+	return s.update(ctx, func(doc *document) error {
+		if rowIdx < 0 || rowIdx >= len(doc.Repos) {
+			return errMissingRow
+		}
+		if current := doc.Repos[rowIdx].Tag; current != expectedTag {
+			return fmt.Errorf("%w: expected %q, found %q", structures.ErrTagConflict, expectedTag, current)
+		}
+		doc.Repos[rowIdx].Time = timestamp
+		doc.Repos[rowIdx].Tag = tag
+		doc.Repos[rowIdx].User = user
+		return nil
+	})
+}
+
+func (s *Store) AppendHistory(ctx context.Context, rec structures.HistoryRecord) error {
+	return s.update(ctx, func(doc *document) error {
+		doc.History = append(doc.History, rec)
+		return nil
+	})
+}
+
+// ListHistory returns the history of the given repository, oldest first.
+func (s *Store) ListHistory(ctx context.Context, repo string) ([]structures.HistoryRecord, error) {
+	doc, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var records []structures.HistoryRecord
+	for _, rec := range doc.History {
+		if rec.Repo == repo {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// AcquireLock takes the build lock of repo. The file lock makes the check and
+// the write atomic, so unlike the sheet backend no read-back is needed.
+func (s *Store) AcquireLock(ctx context.Context, repo, owner string, ttl time.Duration, steal bool) (*structures.Lock, error) {
+	now := time.Now().UTC()
+	lock := structures.Lock{Repo: repo, Owner: owner, Expires: now.Add(ttl)}
+
+	err := s.update(ctx, func(doc *document) error {
+		for i, l := range doc.Locks {
+			if l.Repo != repo {
+				continue
+			}
+			if l.Owner != "" && l.Owner != owner && l.Expires.After(now) && !steal {
+				return fmt.Errorf("%w: %s holds %s until %s", structures.ErrLockHeld, l.Owner, repo, l.Expires.Local().Format(time.Kitchen))
+			}
+			doc.Locks[i] = lock
+			return nil
+		}
+		doc.Locks = append(doc.Locks, lock)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+func (s *Store) ReleaseLock(ctx context.Context, repo, owner string) error {
+	return s.update(ctx, func(doc *document) error {
+		for i, l := range doc.Locks {
+			if l.Repo == repo && l.Owner == owner {
+				doc.Locks = append(doc.Locks[:i], doc.Locks[i+1:]...)
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) read() (*document, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &document{}, nil
+		}
+		return nil, err
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	return &doc, nil
+}
+
+// update applies fn to the document while holding the lock and writes the result atomically.
+func (s *Store) update(ctx context.Context, fn func(doc *document) error) error {
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	doc, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(doc); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // keep "name <email>" readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// lock creates the lock file exclusively. O_EXCL works on every platform forklift
+// is released for and on most network filesystems, unlike flock. While the lock
+// is held its modification time is refreshed, so other processes never take it
+// for the stale lock of a crashed process.
+func (s *Store) lock(ctx context.Context) (func(), error) {
+	lockPath := s.path + ".lock"
+	deadline := time.Now().Add(s.lockTimeout)
+	host, _ := os.Hostname()
+	token := fmt.Sprintf("%d@%s %d\n", os.Getpid(), host, time.Now().UnixNano())
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.WriteString(token)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf("failed to lock %s: %w", s.path, err)
+			}
+			return s.holdLock(lockPath, token), nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock %s: %w", s.path, err)
+		}
+
+		// Break locks left behind by crashed processes
+		if s.breakStaleLock(lockPath) {
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", lockPath)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// holdLock refreshes the lock file until the returned function is called,
+// which removes the lock file unless another process has taken it over.
+func (s *Store) holdLock(lockPath, token string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.staleLockAge / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if owner, _ := readLockFile(lockPath); owner == token {
+					now := time.Now()
+					os.Chtimes(lockPath, now, now)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		if owner, _ := readLockFile(lockPath); owner == token {
+			os.Remove(lockPath)
+		}
+	}
+}
+
+// breakStaleLock removes the lock file if it has not been refreshed for
+// staleLockAge. The owner and modification time are checked again right
+// before removing it, so a lock another process just took is left alone.
+func (s *Store) breakStaleLock(lockPath string) bool {
+	fi, err := os.Stat(lockPath)
+	if err != nil || time.Since(fi.ModTime()) <= s.staleLockAge {
+		return false
+	}
+	owner, err := readLockFile(lockPath)
+	if err != nil {
+		return false
+	}
+	if again, err := os.Stat(lockPath); err != nil || !again.ModTime().Equal(fi.ModTime()) {
+		return false
+	}
+	if current, err := readLockFile(lockPath); err != nil || current != owner {
+		return false
+	}
+	return os.Remove(lockPath) == nil
+}
+
+// readLockFile returns the owner written to a lock file.
+func readLockFile(lockPath string) (string, error) {
+	data, err := os.ReadFile(lockPath)
+	return string(data), err
+}
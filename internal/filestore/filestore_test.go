@@ -0,0 +1,346 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"forklift/internal/structures"
+)
+
+func newTestStore(t *testing.T, data string) *Store {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if data != "" {
+		if err := os.WriteFile(s.path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestNewRequiresAbsolutePath(t *testing.T) {
+	if _, err := New("state.json"); err == nil {
+		t.Error("New() with a relative path succeeded")
+	}
+}
+
+func TestGetRepoInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		repo    string
+		want    *structures.RepoInfo
+		wantErr string
+	}{
+		{
+			name: "missing file",
+			repo: "org/repo",
+		},
+		{
+			name: "unknown repo",
+			data: `{"repos": [{"repo": "org/other", "branch": "main"}]}`,
+			repo: "org/repo",
+		},
+		{
+			name: "all fields",
+			data: `{"repos": [
+				{"repo": "org/other", "branch": "main"},
+				{"repo": "org/repo", "branch": " dev ", "tag": "v-dev-0.0.1 ", "user": "alice",
+				 "owner": "team-a", "environment": "staging", "notes": "frozen",
+				 "tag_template": "build-{seq}", "merge_strategy": "squash"}
+			]}`,
+			repo: "org/repo",
+			want: &structures.RepoInfo{
+				RowIdx:        1,
+				MergeBranch:   "dev",
+				LatestTag:     "v-dev-0.0.1",
+				LastUser:      "alice",
+				Owner:         "team-a",
+				Environment:   "staging",
+				Notes:         "frozen",
+				TagTemplate:   "build-{seq}",
+				MergeStrategy: "squash",
+			},
+		},
+		{
+			name:    "malformed file",
+			data:    `{"repos": [`,
+			repo:    "org/repo",
+			wantErr: "failed to parse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, tt.data)
+			got, err := s.GetRepoInfo(context.Background(), tt.repo)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetRepoInfo() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetRepoInfo() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRepoInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := newTestStore(t, "")
+	ctx := context.Background()
+
+	for _, repo := range []string{"org/repo", "org/other"} {
+		if err := s.SetMergeBranch(ctx, repo, "dev", -1); err != nil {
+			t.Fatalf("SetMergeBranch() error = %v", err)
+		}
+	}
+	if err := s.UpdateRepoTag(ctx, 0, "", "v-dev-0.0.1"); err != nil {
+		t.Fatalf("UpdateRepoTag() error = %v", err)
+	}
+	records := []structures.HistoryRecord{
+		{Repo: "org/repo", MergeBranch: "dev", Tag: "v-dev-0.0.1", User: "Alice <alice@example.com>", Outcome: structures.OutcomeSuccess},
+		{Repo: "org/other", MergeBranch: "dev", Outcome: structures.OutcomeConflict},
+	}
+	for _, rec := range records {
+		if err := s.AppendHistory(ctx, rec); err != nil {
+			t.Fatalf("AppendHistory() error = %v", err)
+		}
+	}
+
+	// A second store reads what the first one wrote
+	s2, err := New(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := s2.GetRepoInfo(ctx, "org/repo")
+	if err != nil || info == nil || info.RowIdx != 0 || info.MergeBranch != "dev" || info.LatestTag != "v-dev-0.0.1" {
+		t.Errorf("GetRepoInfo() = %+v, %v", info, err)
+	}
+	history, err := s2.ListHistory(ctx, "org/repo")
+	if err != nil || !reflect.DeepEqual(history, records[:1]) {
+		t.Errorf("ListHistory() = %+v, %v, want %+v", history, err, records[:1])
+	}
+
+	// Setting the branch again starts a new sequence in the same entry
+	if err := s2.SetMergeBranch(ctx, "org/repo", "prod", 0); err != nil {
+		t.Fatalf("SetMergeBranch() error = %v", err)
+	}
+	info, _ = s.GetRepoInfo(ctx, "org/repo")
+	if info.RowIdx != 0 || info.MergeBranch != "prod" || info.LatestTag != "" {
+		t.Errorf("GetRepoInfo() after SetMergeBranch = %+v, want prod with no tag in row 0", info)
+	}
+
+	data, _ := os.ReadFile(s.path)
+	if !strings.Contains(string(data), "Alice <alice@example.com>") {
+		t.Errorf("user escaped in %s", data)
+	}
+	if _, err := os.Stat(s.path + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestUpdateRepoTag(t *testing.T) {
+	const data = `{"repos": [{"repo": "org/repo", "branch": "dev", "tag": "v-dev-0.0.1"}]}`
+	tests := []struct {
+		name     string
+		rowIdx   int
+		expected string
+		wantTag  string
+		wantErr  error
+	}{
+		{
+			name:     "updates tag",
+			expected: "v-dev-0.0.1",
+			wantTag:  "v-dev-0.0.2",
+		},
+		{
+			name:     "tag changed concurrently",
+			expected: "",
+			wantTag:  "v-dev-0.0.1",
+			wantErr:  structures.ErrTagConflict,
+		},
+		{
+			name:    "missing row",
+			rowIdx:  1,
+			wantTag: "v-dev-0.0.1",
+			wantErr: errMissingRow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, data)
+			ctx := context.Background()
+			err := s.UpdateRepoTag(ctx, tt.rowIdx, tt.expected, "v-dev-0.0.2")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateRepoTag() error = %v, want %v", err, tt.wantErr)
+			}
+			if info, _ := s.GetRepoInfo(ctx, "org/repo"); info.LatestTag != tt.wantTag {
+				t.Errorf("LatestTag = %q, want %q", info.LatestTag, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name    string
+		data    string
+		owner   string
+		steal   bool
+		wantErr error
+	}{
+		{
+			name:  "free",
+			owner: "alice",
+		},
+		{
+			name:  "held by the same owner",
+			data:  `{"locks": [{"repo": "org/repo", "owner": "alice", "expires": "` + future + `"}]}`,
+			owner: "alice",
+		},
+		{
+			name:    "held by another owner",
+			data:    `{"locks": [{"repo": "org/repo", "owner": "bob", "expires": "` + future + `"}]}`,
+			owner:   "alice",
+			wantErr: structures.ErrLockHeld,
+		},
+		{
+			name:  "stolen from another owner",
+			data:  `{"locks": [{"repo": "org/repo", "owner": "bob", "expires": "` + future + `"}]}`,
+			owner: "alice",
+			steal: true,
+		},
+		{
+			name:  "expired",
+			data:  `{"locks": [{"repo": "org/repo", "owner": "bob", "expires": "` + past + `"}]}`,
+			owner: "alice",
+		},
+		{
+			name:  "held on another repo",
+			data:  `{"locks": [{"repo": "org/other", "owner": "bob", "expires": "` + future + `"}]}`,
+			owner: "alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, tt.data)
+			ctx := context.Background()
+			lock, err := s.AcquireLock(ctx, "org/repo", tt.owner, 10*time.Minute, tt.steal)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AcquireLock() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if lock.Owner != tt.owner || time.Until(lock.Expires) < 9*time.Minute {
+				t.Errorf("AcquireLock() = %+v, want %s for 10 minutes", lock, tt.owner)
+			}
+			// Another owner is shut out until the lock is released
+			if _, err := s.AcquireLock(ctx, "org/repo", "carol", time.Minute, false); !errors.Is(err, structures.ErrLockHeld) {
+				t.Errorf("AcquireLock() by another owner error = %v, want %v", err, structures.ErrLockHeld)
+			}
+			if err := s.ReleaseLock(ctx, "org/repo", "carol"); err != nil {
+				t.Fatalf("ReleaseLock() by another owner error = %v", err)
+			}
+			if _, err := s.AcquireLock(ctx, "org/repo", "carol", time.Minute, false); !errors.Is(err, structures.ErrLockHeld) {
+				t.Errorf("lock released by another owner")
+			}
+			if err := s.ReleaseLock(ctx, "org/repo", tt.owner); err != nil {
+				t.Fatalf("ReleaseLock() error = %v", err)
+			}
+			if _, err := s.AcquireLock(ctx, "org/repo", "carol", time.Minute, false); err != nil {
+				t.Errorf("AcquireLock() after release error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		age     time.Duration // of the lock file another process left, 0 for none
+		wantErr string
+	}{
+		{name: "free"},
+		{name: "held", age: time.Millisecond, wantErr: "timed out waiting for lock"},
+		{name: "stale", age: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, "")
+			s.lockTimeout = 200 * time.Millisecond
+			lockPath := s.path + ".lock"
+			if tt.age > 0 {
+				if err := os.WriteFile(lockPath, []byte("1@elsewhere 1\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				mtime := time.Now().Add(-tt.age)
+				if err := os.Chtimes(lockPath, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := s.SetMergeBranch(context.Background(), "org/repo", "dev", -1)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SetMergeBranch() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(lockPath); err != nil {
+					t.Errorf("live lock file was removed: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetMergeBranch() error = %v", err)
+			}
+			if _, err := os.Stat(lockPath); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("lock file left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileLockRefreshedWhileHeld(t *testing.T) {
+	s := newTestStore(t, "")
+	s.staleLockAge = 150 * time.Millisecond
+	s.lockTimeout = 500 * time.Millisecond
+	ctx := context.Background()
+
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+	// Outlive staleLockAge several times; the lock must not be broken
+	if _, err := s.lock(ctx); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("second lock() error = %v, want time out", err)
+	}
+
+	// A lock file another process took over is not removed on unlock
+	lockPath := s.path + ".lock"
+	if err := os.WriteFile(lockPath, []byte("1@elsewhere 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if data, err := os.ReadFile(lockPath); err != nil || string(data) != "1@elsewhere 1\n" {
+		t.Errorf("lock file = %q, %v; want the other process's lock kept", data, err)
+	}
+}
@@ -3,84 +3,441 @@ package git
 import (
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
 
-// Helpers
+// StashMessage marks the stashes forklift creates, so they can be told apart
+// from the developer's own.
+const StashMessage = "forklift-auto-stash"
 
-func Stash() (bool, error) {
+// Helpers. The package-level functions run on Default; use a Runner's methods
+// to work in another directory or with a fake.
+
+func Stash() (bool, error)                     { return Default.Stash() }
+func StashPop() error                          { return Default.StashPop() }
+func FindStash(message string) (string, error) { return Default.FindStash(message) }
+func PopStash(ref string) error                { return Default.PopStash(ref) }
+func IsMergeInProgress() bool                  { return Default.IsMergeInProgress() }
+func TagExists(tag string) bool                { return Default.TagExists(tag) }
+func CurrentBranch() (string, error)           { return Default.CurrentBranch() }
+func HeadCommit() (string, error)              { return Default.HeadCommit() }
+func GitDir() (string, error)                  { return Default.GitDir() }
+func TopLevel() (string, error)                { return Default.TopLevel() }
+func Checkout(branch string) error             { return Default.Checkout(branch) }
+func Fetch(remote, branch string) error        { return Default.Fetch(remote, branch) }
+func Pull(remote, branch string) error         { return Default.Pull(remote, branch) }
+func Merge(branch string) error                { return Default.Merge(branch) }
+func AbortMerge() error                        { return Default.AbortMerge() }
+func MergeFastForward(branch string) error     { return Default.MergeFastForward(branch) }
+func MergeSquash(branch string) error          { return Default.MergeSquash(branch) }
+func IsSquashInProgress() bool                 { return Default.IsSquashInProgress() }
+func AbortSquash() error                       { return Default.AbortSquash() }
+func CommitStaged(message string) error        { return Default.CommitStaged(message) }
+func Rebase(upstream string) error             { return Default.Rebase(upstream) }
+func IsRebaseInProgress() bool                 { return Default.IsRebaseInProgress() }
+func AbortRebase() error                       { return Default.AbortRebase() }
+func DeleteBranch(branch string) error         { return Default.DeleteBranch(branch) }
+func PushBranch(remote, branch string) error   { return Default.PushBranch(remote, branch) }
+func TagWithMessage(tag, message string, sign bool) error {
+	return Default.TagWithMessage(tag, message, sign)
+}
+func VerifyTag(tag string) error                          { return Default.VerifyTag(tag) }
+func Commits(from, to string) ([]Commit, error)           { return Default.Commits(from, to) }
+func PreviousTag(tag string) (string, error)              { return Default.PreviousTag(tag) }
+func Log(from, to string) ([]string, error)               { return Default.Log(from, to) }
+func Tag(tag string) error                                { return Default.Tag(tag) }
+func DeleteTag(tag string) error                          { return Default.DeleteTag(tag) }
+func PushTag(remote, tag string) error                    { return Default.PushTag(remote, tag) }
+func AddWorktree(path, branch string) error               { return Default.AddWorktree(path, branch) }
+func RemoveWorktree(path string) error                    { return Default.RemoveWorktree(path) }
+func RemoteTagExists(remote, tag string) bool             { return Default.RemoteTagExists(remote, tag) }
+func RemoteTags(remote string) (map[string]string, error) { return Default.RemoteTags(remote) }
+func LocalTags() (map[string]string, error)               { return Default.LocalTags() }
+func FetchTags(remote string, tags ...string) error       { return Default.FetchTags(remote, tags...) }
+func DetectRepoName() (string, error)                     { return Default.DetectRepoName() }
+func UserIdentity() string                                { return Default.UserIdentity() }
+
+func (r *Runner) Stash() (bool, error) {
 	// Use a message so we can identify our stash if needed
-	cmd := exec.Command("git", "stash", "push", "-m", "forklift-auto-stash")
-	out, err := cmd.CombinedOutput()
+	out, err := r.Run("stash", "push", "-m", StashMessage)
 	if err != nil {
 		return false, err
 	}
-	output := string(out)
-	if strings.Contains(output, "No local changes to save") {
+	if strings.Contains(out, "No local changes to save") {
 		return false, nil
 	}
 	return true, nil
 }
 
-func StashPop() error {
-	return exec.Command("git", "stash", "pop").Run()
+func (r *Runner) StashPop() error {
+	_, err := r.Run("stash", "pop")
+	return err
 }
 
-func IsMergeInProgress() bool {
+// FindStash returns the ref (e.g. stash@{2}) of the newest stash saved with
+// message, or "" if there is none.
+func (r *Runner) FindStash(message string) (string, error) {
+	out, err := r.Run("stash", "list", "--format=%gd %gs")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		ref, subject, ok := strings.Cut(line, " ")
+		if ok && strings.HasSuffix(subject, ": "+message) {
+			return ref, nil
+		}
+	}
+	return "", nil
+}
+
+// PopStash applies and drops the stash at ref.
+func (r *Runner) PopStash(ref string) error {
+	_, err := r.Run("stash", "pop", ref)
+	return err
+}
+
+func (r *Runner) IsMergeInProgress() bool {
 	// Check if .git/MERGE_HEAD exists
-	cmd := exec.Command("git", "rev-parse", "-q", "--verify", "MERGE_HEAD")
-	err := cmd.Run()
-	return err == nil
+	return r.Succeeds("rev-parse", "-q", "--verify", "MERGE_HEAD")
 }
 
-func TagExists(tag string) bool {
-	err := exec.Command("git", "rev-parse", tag).Run()
-	return err == nil
+func (r *Runner) TagExists(tag string) bool {
+	return r.Succeeds("rev-parse", "-q", "--verify", "refs/tags/"+tag)
 }
 
-func CurrentBranch() (string, error) {
-	out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+func (r *Runner) CurrentBranch() (string, error) {
+	return r.Run("rev-parse", "--abbrev-ref", "HEAD")
+}
+
+func (r *Runner) HeadCommit() (string, error) {
+	return r.Run("rev-parse", "HEAD")
+}
+
+// GitDir returns the absolute path of the git directory shared by all worktrees
+// of the repository.
+func (r *Runner) GitDir() (string, error) {
+	dir, err := r.Run("rev-parse", "--git-common-dir")
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(out)), nil
+	if filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	base := r.Dir
+	if base == "" {
+		if base, err = os.Getwd(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(base, dir), nil
+}
+
+// TopLevel returns the root directory of the current worktree.
+func (r *Runner) TopLevel() (string, error) {
+	return r.Run("rev-parse", "--show-toplevel")
+}
+
+// ResetHard moves the current branch to ref, discarding uncommitted changes.
+func (r *Runner) ResetHard(ref string) error {
+	_, err := r.Run("reset", "-q", "--hard", ref)
+	return err
+}
+
+func (r *Runner) Checkout(branch string) error {
+	_, err := r.Run("checkout", branch)
+	return err
+}
+
+func (r *Runner) Fetch(remote, branch string) error {
+	_, err := r.Run("fetch", remote, branch)
+	return err
+}
+
+func (r *Runner) Pull(remote, branch string) error {
+	_, err := r.Run("pull", remote, branch)
+	return err
+}
+
+func (r *Runner) Merge(branch string) error {
+	_, err := r.Run("merge", branch, "--no-edit")
+	return err
+}
+
+func (r *Runner) AbortMerge() error {
+	_, err := r.Run("merge", "--abort")
+	return err
+}
+
+// MergeFastForward moves the current branch up to branch, failing unless
+// that is possible without a merge commit.
+func (r *Runner) MergeFastForward(branch string) error {
+	_, err := r.Run("merge", "--ff-only", branch)
+	return err
+}
+
+// MergeSquash stages the changes of branch as one change on top of the
+// current branch, without committing them.
+func (r *Runner) MergeSquash(branch string) error {
+	_, err := r.Run("merge", "--squash", branch)
+	return err
+}
+
+// IsSquashInProgress reports whether a squash merge is staged but not yet
+// committed. Unlike a merge, a squash leaves no MERGE_HEAD, only SQUASH_MSG.
+func (r *Runner) IsSquashInProgress() bool {
+	return r.gitPathExists("SQUASH_MSG")
+}
+
+// WriteSquashMessage replaces the message 'git commit' proposes for a
+// squash in progress.
+func (r *Runner) WriteSquashMessage(message string) error {
+	path, err := r.gitPath("SQUASH_MSG")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(message), 0644)
+}
+
+// AbortSquash throws away a squash in progress.
+func (r *Runner) AbortSquash() error {
+	if _, err := r.Run("reset", "--merge"); err != nil {
+		return err
+	}
+	path, err := r.gitPath("SQUASH_MSG")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
-func Checkout(branch string) error {
-	return exec.Command("git", "checkout", branch).Run()
+// HasStagedChanges reports whether the index differs from HEAD.
+func (r *Runner) HasStagedChanges() bool {
+	return !r.Succeeds("diff", "--cached", "--quiet")
 }
 
-func Pull(remote, branch string) error {
-	return exec.Command("git", "pull", remote, branch).Run()
+// CommitStaged commits the staged changes.
+func (r *Runner) CommitStaged(message string) error {
+	_, err := r.Run("commit", "-q", "-m", message)
+	return err
 }
 
-func Merge(branch string) error {
-	return exec.Command("git", "merge", branch, "--no-edit").Run()
+// Rebase replays the commits of the current branch on top of upstream.
+func (r *Runner) Rebase(upstream string) error {
+	_, err := r.Run("rebase", upstream)
+	return err
 }
 
-func PushBranch(remote, branch string) error {
-	return exec.Command("git", "push", remote, branch).Run()
+// IsRebaseInProgress reports whether a rebase stopped, e.g. on conflicts.
+func (r *Runner) IsRebaseInProgress() bool {
+	return r.gitPathExists("rebase-merge") || r.gitPathExists("rebase-apply")
 }
 
-func Tag(tag string) error {
-	return exec.Command("git", "tag", tag).Run()
+func (r *Runner) AbortRebase() error {
+	_, err := r.Run("rebase", "--abort")
+	return err
 }
 
-func PushTag(remote, tag string) error {
-	return exec.Command("git", "push", remote, tag).Run()
+// CheckoutNew creates or resets branch to start and checks it out.
+func (r *Runner) CheckoutNew(branch, start string) error {
+	_, err := r.Run("checkout", "-B", branch, start)
+	return err
 }
 
-func DetectRepoName() (string, error) {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Stderr = os.Stderr
-	output, err := cmd.Output()
+func (r *Runner) BranchExists(branch string) bool {
+	return r.Succeeds("rev-parse", "-q", "--verify", "refs/heads/"+branch)
+}
+
+func (r *Runner) DeleteBranch(branch string) error {
+	_, err := r.Run("branch", "-D", branch)
+	return err
+}
+
+// gitPath resolves name inside the git directory of the current worktree.
+func (r *Runner) gitPath(name string) (string, error) {
+	path, err := r.Run("rev-parse", "--git-path", name)
 	if err != nil {
 		return "", err
 	}
+	if !filepath.IsAbs(path) && r.Dir != "" {
+		path = filepath.Join(r.Dir, path)
+	}
+	return path, nil
+}
+
+func (r *Runner) gitPathExists(name string) bool {
+	path, err := r.gitPath(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+func (r *Runner) PushBranch(remote, branch string) error {
+	_, err := r.Run("push", remote, branch)
+	return err
+}
+
+func (r *Runner) Tag(tag string) error {
+	_, err := r.Run("tag", tag)
+	return err
+}
+
+// TagWithMessage creates an annotated tag, signed with the user's configured
+// GPG or SSH key (user.signingkey, gpg.format) if sign is set.
+func (r *Runner) TagWithMessage(tag, message string, sign bool) error {
+	flag := "-a"
+	if sign {
+		flag = "-s"
+	}
+	_, err := r.Run("tag", flag, tag, "-m", message)
+	return err
+}
+
+// VerifyTag checks the signature of tag.
+func (r *Runner) VerifyTag(tag string) error {
+	_, err := r.Run("tag", "-v", tag)
+	return err
+}
+
+// Log returns "<short sha> <subject>" for each commit reachable from to but
+// not from from, newest first. An empty from lists the whole history of to.
+func (r *Runner) Log(from, to string) ([]string, error) {
+	rev := to
+	if from != "" {
+		rev = from + ".." + to
+	}
+	out, err := r.Run("log", "--no-merges", "--format=%h %s", rev)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// Commit is a commit as listed by Commits.
+type Commit struct {
+	SHA     string // abbreviated
+	Subject string
+}
+
+// Commits lists the commits reachable from to but not from from, including
+// merges, newest first. An empty from lists the whole history of to.
+func (r *Runner) Commits(from, to string) ([]Commit, error) {
+	rev := to
+	if from != "" {
+		rev = from + ".." + to
+	}
+	out, err := r.Run("log", "--format=%h%x1f%s", rev)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for _, line := range strings.Split(out, "\n") {
+		if sha, subject, ok := strings.Cut(line, "\x1f"); ok {
+			commits = append(commits, Commit{SHA: sha, Subject: subject})
+		}
+	}
+	return commits, nil
+}
+
+// PreviousTag returns the closest tag reachable from the parent of tag.
+func (r *Runner) PreviousTag(tag string) (string, error) {
+	return r.Run("describe", "--tags", "--abbrev=0", "refs/tags/"+tag+"^")
+}
+
+func (r *Runner) DeleteTag(tag string) error {
+	_, err := r.Run("tag", "-d", tag)
+	return err
+}
+
+func (r *Runner) PushTag(remote, tag string) error {
+	_, err := r.Run("push", remote, "refs/tags/"+tag)
+	return err
+}
+
+// AddWorktree checks branch out into a new worktree at path.
+func (r *Runner) AddWorktree(path, branch string) error {
+	_, err := r.Run("worktree", "add", path, branch)
+	return err
+}
+
+// RemoveWorktree deletes the worktree at path, including uncommitted changes in it.
+func (r *Runner) RemoveWorktree(path string) error {
+	_, err := r.Run("worktree", "remove", "--force", path)
+	return err
+}
+
+// RemoteTagExists reports whether remote has the given tag.
+func (r *Runner) RemoteTagExists(remote, tag string) bool {
+	out, err := r.Run("ls-remote", "--tags", remote, "refs/tags/"+tag)
+	return err == nil && out != ""
+}
+
+// RemoteTags lists the tags of remote, mapped to the commits they point at.
+func (r *Runner) RemoteTags(remote string) (map[string]string, error) {
+	out, err := r.Run("ls-remote", "--tags", remote)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		sha, ref, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(ref, "refs/tags/")
+		if peeled, ok := strings.CutSuffix(name, "^{}"); ok {
+			// Annotated tag: the peeled line has the commit
+			tags[peeled] = sha
+		} else if _, seen := tags[name]; !seen {
+			tags[name] = sha
+		}
+	}
+	return tags, nil
+}
+
+// LocalTags lists the local tags, mapped to the commits they point at.
+func (r *Runner) LocalTags() (map[string]string, error) {
+	out, err := r.Run("for-each-ref", "--format=%(refname:strip=2)%09%(objectname)%09%(*objectname)", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		// The peeled commit is empty for lightweight tags
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		tags[fields[0]] = fields[1]
+		if len(fields) == 3 && fields[2] != "" {
+			tags[fields[0]] = fields[2]
+		}
+	}
+	return tags, nil
+}
+
+// FetchTags fetches the given tags from remote, overwriting local tags of
+// the same name.
+func (r *Runner) FetchTags(remote string, tags ...string) error {
+	args := []string{"fetch", "--no-tags", remote}
+	for _, tag := range tags {
+		args = append(args, fmt.Sprintf("+refs/tags/%s:refs/tags/%s", tag, tag))
+	}
+	_, err := r.Run(args...)
+	return err
+}
 
-	remote := strings.TrimSpace(string(output))
+func (r *Runner) DetectRepoName() (string, error) {
+	remote, err := r.Run("remote", "get-url", "origin")
+	if err != nil {
+		return "", err
+	}
 	if remote == "" {
 		return "", fmt.Errorf("origin remote is empty")
 	}
@@ -126,11 +483,9 @@ func ParseRepoName(remote string) (string, error) {
 	return "", fmt.Errorf("unable to parse repo from remote: %s", remote)
 }
 
-func UserIdentity() string {
-	nameOut, _ := exec.Command("git", "config", "user.name").Output()
-	emailOut, _ := exec.Command("git", "config", "user.email").Output()
-	name := strings.TrimSpace(string(nameOut))
-	email := strings.TrimSpace(string(emailOut))
+func (r *Runner) UserIdentity() string {
+	name, _ := r.Run("config", "user.name")
+	email, _ := r.Run("config", "user.email")
 
 	if name == "" && email == "" {
 		host, _ := os.Hostname()
@@ -0,0 +1,184 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGit records the commands it is asked to run and answers from a table
+// keyed by the joined arguments.
+type fakeGit struct {
+	calls   [][]string
+	results map[string]fakeResult
+}
+
+type fakeResult struct {
+	stdout, stderr string
+	exitCode       int
+}
+
+func (f *fakeGit) runner() *Runner {
+	return &Runner{
+		Dir: "/repo",
+		Exec: func(ctx context.Context, dir string, env []string, args []string) (string, string, int, error) {
+			f.calls = append(f.calls, args)
+			res := f.results[strings.Join(args, " ")]
+			return res.stdout, res.stderr, res.exitCode, nil
+		},
+	}
+}
+
+func TestRunnerError(t *testing.T) {
+	f := &fakeGit{results: map[string]fakeResult{
+		"pull origin dev": {stderr: "fatal: couldn't find remote ref dev\n", exitCode: 1},
+	}}
+
+	err := f.runner().Pull("origin", "dev")
+
+	var gitErr *Error
+	if !errors.As(err, &gitErr) {
+		t.Fatalf("Pull() error = %v, want *Error", err)
+	}
+	if gitErr.ExitCode != 1 || gitErr.Dir != "/repo" || !reflect.DeepEqual(gitErr.Args, []string{"pull", "origin", "dev"}) {
+		t.Errorf("Error = %+v", gitErr)
+	}
+	want := "git pull origin dev exited with status 1: fatal: couldn't find remote ref dev"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestStash(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  fakeResult
+		want    bool
+		wantErr bool
+	}{
+		{"changes stashed", fakeResult{stdout: "Saved working directory and index state On dev: forklift-auto-stash\n"}, true, false},
+		{"nothing to stash", fakeResult{stdout: "No local changes to save\n"}, false, false},
+		{"failure", fakeResult{stderr: "fatal: not a git repository", exitCode: 128}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeGit{results: map[string]fakeResult{"stash push -m forklift-auto-stash": tt.result}}
+			got, err := f.runner().Stash()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Stash() = %v, %v; want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFindStash(t *testing.T) {
+	f := &fakeGit{results: map[string]fakeResult{
+		"stash list --format=%gd %gs": {stdout: "stash@{0} WIP on dev: 1a2b3c4 wip\nstash@{1} On feature/x: forklift-auto-stash\nstash@{2} On dev: forklift-auto-stash\n"},
+	}}
+	got, err := f.runner().FindStash(StashMessage)
+	if err != nil || got != "stash@{1}" {
+		t.Errorf("FindStash() = %q, %v; want stash@{1}", got, err)
+	}
+
+	f = &fakeGit{results: map[string]fakeResult{
+		"stash list --format=%gd %gs": {stdout: "stash@{0} On dev: my own forklift-auto-stash notes\n"},
+	}}
+	if got, err := f.runner().FindStash(StashMessage); err != nil || got != "" {
+		t.Errorf("FindStash() = %q, %v; want no match", got, err)
+	}
+}
+
+func TestRemoteTags(t *testing.T) {
+	f := &fakeGit{results: map[string]fakeResult{
+		"ls-remote --tags origin": {stdout: "1111111111111111111111111111111111111111\trefs/tags/v-dev-0.0.1\n" +
+			"2222222222222222222222222222222222222222\trefs/tags/v-dev-0.0.2\n" +
+			"3333333333333333333333333333333333333333\trefs/tags/v-dev-0.0.2^{}\n"},
+	}}
+	got, err := f.runner().RemoteTags("origin")
+	want := map[string]string{
+		"v-dev-0.0.1": "1111111111111111111111111111111111111111",
+		"v-dev-0.0.2": "3333333333333333333333333333333333333333",
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("RemoteTags() = %v, %v; want %v", got, err, want)
+	}
+}
+
+func TestLocalTags(t *testing.T) {
+	// Run trims the output, so the last line loses its empty peeled column
+	f := &fakeGit{results: map[string]fakeResult{
+		"for-each-ref --format=%(refname:strip=2)%09%(objectname)%09%(*objectname) refs/tags": {stdout: "v-dev-0.0.1\t1111111111111111111111111111111111111111\t3333333333333333333333333333333333333333\n" +
+			"v-dev-0.0.2\t2222222222222222222222222222222222222222\t\n"},
+	}}
+	got, err := f.runner().LocalTags()
+	want := map[string]string{
+		"v-dev-0.0.1": "3333333333333333333333333333333333333333",
+		"v-dev-0.0.2": "2222222222222222222222222222222222222222",
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("LocalTags() = %v, %v; want %v", got, err, want)
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	r := &Runner{
+		Timeout: 10 * time.Millisecond,
+		Exec: func(ctx context.Context, dir string, env []string, args []string) (string, string, int, error) {
+			<-ctx.Done()
+			return "", "", -1, ctx.Err()
+		},
+	}
+	_, err := r.Run("fetch")
+	var gitErr *Error
+	if !errors.As(err, &gitErr) || gitErr.ExitCode != -1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v, want timeout *Error", err)
+	}
+}
+
+func TestRunnerDirAndEnv(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	r := &Runner{Dir: dir, Env: []string{"GIT_CONFIG_GLOBAL=" + os.DevNull}}
+	if _, err := r.Run("init", "-q"); err != nil {
+		t.Fatalf("git init: %v", err)
+	}
+
+	got, err := r.GitDir()
+	if err != nil {
+		t.Fatalf("GitDir() error = %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(dir, ".git"))
+	if got, _ := filepath.EvalSymlinks(got); got != want {
+		t.Errorf("GitDir() = %s, want %s", got, want)
+	}
+
+	_, err = r.Run("rev-parse", "--verify", "HEAD")
+	var gitErr *Error
+	if !errors.As(err, &gitErr) || gitErr.ExitCode == 0 || gitErr.Stderr == "" {
+		t.Errorf("rev-parse on empty repo: error = %v, want *Error with stderr", err)
+	}
+}
+
+func TestParseRepoName(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:org/repo.git":        "org/repo",
+		"https://github.com/org/repo":        "org/repo",
+		"ssh://git@github.com/org/repo.git":  "org/repo",
+		"/srv/git/org/repo.git":              "org/repo",
+		"https://gitlab.example.com/org/sub": "org/sub",
+	}
+	for remote, want := range tests {
+		got, err := ParseRepoName(remote)
+		if err != nil || got != want {
+			t.Errorf("ParseRepoName(%q) = %q, %v; want %q", remote, got, err, want)
+		}
+	}
+}
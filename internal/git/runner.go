@@ -0,0 +1,118 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Runner runs git commands. The zero value runs git in the current directory
+// with the current environment and no timeout.
+type Runner struct {
+	Dir     string        // working directory, "" for the current one
+	Env     []string      // extra KEY=value pairs on top of the current environment
+	Timeout time.Duration // per command, 0 for none
+
+	// Exec, if set, is called instead of the git binary, so tests can fake git.
+	// err is reserved for failures to run the command at all; a command that ran
+	// and failed reports a non-zero exitCode.
+	Exec func(ctx context.Context, dir string, env []string, args []string) (stdout, stderr string, exitCode int, err error)
+}
+
+// Default is the Runner used by the package-level helpers.
+var Default = &Runner{}
+
+// Error describes a git command that failed to run or exited non-zero.
+type Error struct {
+	Args     []string
+	Dir      string
+	ExitCode int // -1 if git did not run to completion
+	Stdout   string
+	Stderr   string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("git %s", strings.Join(e.Args, " "))
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" exited with status %d", e.ExitCode)
+	} else if e.Err != nil {
+		msg += fmt.Sprintf(" failed: %v", e.Err)
+	}
+	if detail := strings.TrimSpace(e.Stderr); detail != "" {
+		msg += ": " + detail
+	} else if detail := strings.TrimSpace(e.Stdout); detail != "" {
+		msg += ": " + detail
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Run runs git with args and returns its trimmed stdout. On failure the error is an *Error.
+func (r *Runner) Run(args ...string) (string, error) {
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	stdout, stderr, exitCode, err := r.exec(ctx, args)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit status %d", exitCode)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s: %w", r.Timeout, ctx.Err())
+			exitCode = -1
+		}
+		return "", &Error{
+			Args:     args,
+			Dir:      r.Dir,
+			ExitCode: exitCode,
+			Stdout:   stdout,
+			Stderr:   stderr,
+			Err:      err,
+		}
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// Succeeds runs git with args and reports whether it exited zero.
+func (r *Runner) Succeeds(args ...string) bool {
+	_, err := r.Run(args...)
+	return err == nil
+}
+
+func (r *Runner) exec(ctx context.Context, args []string) (string, string, int, error) {
+	if r.Exec != nil {
+		return r.Exec(ctx, r.Dir, r.Env, args)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	if len(r.Env) > 0 {
+		cmd.Env = append(os.Environ(), r.Env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), stderr.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return stdout.String(), stderr.String(), -1, err
+	}
+	return stdout.String(), stderr.String(), 0, nil
+}
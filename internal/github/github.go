@@ -1,15 +1,28 @@
 package github
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 // WorkflowStatus represents the status of a GitHub Actions workflow run
 type WorkflowStatus struct {
+	Name       string // name of the workflow
+	Path       string // workflow file, e.g. .github/workflows/ci.yml
+	Event      string // event that triggered the run, e.g. push
+	HeadBranch string // branch or tag the run is for
+	HeadSHA    string
 	Status     string // queued, in_progress, completed
 	Conclusion string // success, failure, cancelled, skipped, null if not completed
 	HTMLURL    string
@@ -17,81 +30,429 @@ type WorkflowStatus struct {
 	CreatedAt  time.Time
 }
 
-// Client is a GitHub API client for checking workflow status
+// DefaultBaseURL is the address of the public GitHub API.
+const DefaultBaseURL = "https://api.github.com"
+
+const (
+	// requestTimeout bounds ordinary API calls.
+	requestTimeout = 10 * time.Second
+	// uploadTimeout bounds release asset uploads, which can be large.
+	uploadTimeout = 10 * time.Minute
+	// downloadTimeout bounds log archive downloads.
+	downloadTimeout = 5 * time.Minute
+)
+
+// Client is a GitHub API client for checking workflow status and publishing releases
 type Client struct {
-	token string
-	owner string
-	repo  string
+	token   string
+	owner   string
+	repo    string
+	baseURL string
+	http    *http.Client
 }
 
 // NewClient creates a new GitHub API client
 func NewClient(token, owner, repo string) *Client {
 	return &Client{
-		token: token,
-		owner: owner,
-		repo:  repo,
+		token:   token,
+		owner:   owner,
+		repo:    repo,
+		baseURL: DefaultBaseURL,
+		http:    &http.Client{Transport: DefaultTransport},
+	}
+}
+
+// transport returns the client's Transport, or nil if it uses another one.
+func (c *Client) transport() *Transport {
+	t, _ := c.http.Transport.(*Transport)
+	return t
+}
+
+// RateLimit returns the API rate limit as of the last response.
+func (c *Client) RateLimit() RateLimit {
+	if t := c.transport(); t != nil {
+		return t.RateLimit()
 	}
+	return RateLimit{}
 }
 
-// CheckWorkflowStatusForTag checks the status of workflow runs triggered by a specific tag
-func (c *Client) CheckWorkflowStatusForTag(tag string) (*WorkflowStatus, error) {
-	// GitHub API endpoint for workflow runs
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/runs?event=push&per_page=10", c.owner, c.repo)
+// Requests returns the number of API requests sent so far by the clients
+// sharing this client's transport.
+func (c *Client) Requests() int {
+	if t := c.transport(); t != nil {
+		return t.Requests()
+	}
+	return 0
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// WithBaseURL points the client at another API root, e.g. a GitHub Enterprise
+// server (https://github.example.com/api/v3) or a test server.
+func (c *Client) WithBaseURL(baseURL string) *Client {
+	if baseURL != "" {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	return c
+}
+
+// APIError is returned for responses with an unexpected status code.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// do sends an authenticated JSON request to path, relative to the base URL,
+// and decodes the response into out unless out is nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	return c.send(req, contentType, requestTimeout, out)
+}
+
+// send adds authentication to req, sends it and decodes a JSON response into
+// out unless out is nil. A *[]byte out receives the raw body instead.
+// Non-2xx responses are returned as *APIError.
+func (c *Client) send(req *http.Request, contentType string, timeout time.Duration, out any) error {
+	req = req.WithContext(withAttemptTimeout(req.Context(), timeout))
+	method := req.Method
 
-	// Add authentication header
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch workflow runs: %w", err)
+		return fmt.Errorf("%s %s failed: %w", method, req.URL.Path, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Body: string(data)}
 	}
-
-	var result struct {
-		WorkflowRuns []struct {
-			ID         int64     `json:"id"`
-			Status     string    `json:"status"`
-			Conclusion *string   `json:"conclusion"`
-			HTMLURL    string    `json:"html_url"`
-			HeadBranch string    `json:"head_branch"`
-			CreatedAt  time.Time `json:"created_at"`
-		} `json:"workflow_runs"`
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		if *raw, err = io.ReadAll(resp.Body); err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
 	}
+	return nil
+}
+
+// runsPerPage is the page size for listing workflow runs and jobs, the API's maximum.
+const runsPerPage = 100
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// CommitSHA returns the SHA of the commit ref, e.g. a tag, points to.
+func (c *Client) CommitSHA(ctx context.Context, ref string) (string, error) {
+	var commit struct {
+		SHA string `json:"sha"`
 	}
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s", c.owner, c.repo, url.PathEscape(ref))
+	if err := c.do(ctx, http.MethodGet, path, nil, &commit); err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	return commit.SHA, nil
+}
+
+// WorkflowRunsForCommit returns the workflow runs of commit sha, newest first,
+// reading every page. A non-empty workflow only matches runs of the workflow
+// with that name or file name (ci.yml).
+func (c *Client) WorkflowRunsForCommit(ctx context.Context, sha, workflow string) ([]WorkflowStatus, error) {
+	var runs []WorkflowStatus
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount   int `json:"total_count"`
+			WorkflowRuns []struct {
+				ID         int64     `json:"id"`
+				Name       string    `json:"name"`
+				Path       string    `json:"path"`
+				Event      string    `json:"event"`
+				HeadBranch string    `json:"head_branch"`
+				HeadSHA    string    `json:"head_sha"`
+				Status     string    `json:"status"`
+				Conclusion *string   `json:"conclusion"`
+				HTMLURL    string    `json:"html_url"`
+				CreatedAt  time.Time `json:"created_at"`
+			} `json:"workflow_runs"`
+		}
+		path := fmt.Sprintf("/repos/%s/%s/actions/runs?head_sha=%s&per_page=%d&page=%d", c.owner, c.repo, url.QueryEscape(sha), runsPerPage, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+			return nil, err
+		}
 
-	// Find the most recent workflow run for this tag
-	for _, run := range result.WorkflowRuns {
-		if run.HeadBranch == tag {
+		for _, run := range result.WorkflowRuns {
+			if !matchesWorkflow(workflow, run.Name, run.Path) {
+				continue
+			}
 			conclusion := ""
 			if run.Conclusion != nil {
 				conclusion = *run.Conclusion
 			}
-			return &WorkflowStatus{
+			runs = append(runs, WorkflowStatus{
+				Name:       run.Name,
+				Path:       run.Path,
+				Event:      run.Event,
+				HeadBranch: run.HeadBranch,
+				HeadSHA:    run.HeadSHA,
 				Status:     run.Status,
 				Conclusion: conclusion,
 				HTMLURL:    run.HTMLURL,
 				RunID:      run.ID,
 				CreatedAt:  run.CreatedAt,
-			}, nil
+			})
+		}
+		if len(result.WorkflowRuns) < runsPerPage || page*runsPerPage >= result.TotalCount {
+			return runs, nil
+		}
+	}
+}
+
+// WorkflowRunsForTag returns the workflow runs triggered by tag, newest
+// first: the runs of the tagged commit whose head branch is the tag. When a
+// workflow ran several times for the tag, only its newest run is returned.
+// An empty result means no run has started yet.
+func (c *Client) WorkflowRunsForTag(ctx context.Context, tag, workflow string) ([]WorkflowStatus, error) {
+	sha, err := c.CommitSHA(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := c.WorkflowRunsForCommit(ctx, sha, workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []WorkflowStatus
+	seen := make(map[string]bool)
+	for _, run := range runs {
+		if run.HeadBranch != tag || seen[run.Path] {
+			continue
 		}
+		seen[run.Path] = true
+		matched = append(matched, run)
 	}
+	return matched, nil
+}
 
-	return nil, fmt.Errorf("no workflow runs found for tag %s", tag)
+// matchesWorkflow reports whether a run of the workflow with the given name
+// and path (.github/workflows/ci.yml) is one of workflow. Empty matches any.
+func matchesWorkflow(workflow, name, path string) bool {
+	return workflow == "" || workflow == name || workflow == filepath.Base(path)
+}
+
+// RerunRun re-runs every job of a completed workflow run.
+func (c *Client) RerunRun(ctx context.Context, runID int64) error {
+	path := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/rerun", c.owner, c.repo, runID)
+	if err := c.do(ctx, http.MethodPost, path, nil, nil); err != nil {
+		return fmt.Errorf("failed to re-run run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// RerunFailedJobs re-runs the failed jobs of a completed workflow run, and
+// the jobs that depend on them.
+func (c *Client) RerunFailedJobs(ctx context.Context, runID int64) error {
+	path := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/rerun-failed-jobs", c.owner, c.repo, runID)
+	if err := c.do(ctx, http.MethodPost, path, nil, nil); err != nil {
+		return fmt.Errorf("failed to re-run the failed jobs of run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// CancelRun cancels a queued or running workflow run.
+func (c *Client) CancelRun(ctx context.Context, runID int64) error {
+	path := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/cancel", c.owner, c.repo, runID)
+	if err := c.do(ctx, http.MethodPost, path, nil, nil); err != nil {
+		return fmt.Errorf("failed to cancel run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// Job is a job of a workflow run.
+type Job struct {
+	ID          int64     `json:"id"`
+	RunID       int64     `json:"run_id"`
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`     // queued, in_progress, completed
+	Conclusion  string    `json:"conclusion"` // success, failure, cancelled, skipped, empty if not completed
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"` // zero if not completed
+	HTMLURL     string    `json:"html_url"`
+	Steps       []Step    `json:"steps"`
+}
+
+// Step is a step of a job.
+type Step struct {
+	Number      int       `json:"number"`
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	Conclusion  string    `json:"conclusion"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Duration returns how long the job ran, or has been running so far.
+func (j Job) Duration() time.Duration {
+	return duration(j.StartedAt, j.CompletedAt)
+}
+
+// Duration returns how long the step ran, or has been running so far.
+func (s Step) Duration() time.Duration {
+	return duration(s.StartedAt, s.CompletedAt)
+}
+
+func duration(started, completed time.Time) time.Duration {
+	if started.IsZero() {
+		return 0
+	}
+	if completed.IsZero() {
+		return time.Since(started)
+	}
+	return completed.Sub(started)
+}
+
+// FailedStep returns the first step of the job that failed, or nil.
+func (j Job) FailedStep() *Step {
+	for i, step := range j.Steps {
+		if step.Conclusion == "failure" || step.Conclusion == "timed_out" {
+			return &j.Steps[i]
+		}
+	}
+	return nil
+}
+
+// JobsForRun returns the jobs of the latest attempt of a workflow run,
+// reading every page.
+func (c *Client) JobsForRun(ctx context.Context, runID int64) ([]Job, error) {
+	var jobs []Job
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount int   `json:"total_count"`
+			Jobs       []Job `json:"jobs"`
+		}
+		path := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/jobs?per_page=%d&page=%d", c.owner, c.repo, runID, runsPerPage, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to list jobs of run %d: %w", runID, err)
+		}
+		jobs = append(jobs, result.Jobs...)
+		if len(result.Jobs) < runsPerPage || page*runsPerPage >= result.TotalCount {
+			return jobs, nil
+		}
+	}
+}
+
+// Release is a GitHub release.
+type Release struct {
+	ID         int64   `json:"id"`
+	TagName    string  `json:"tag_name"`
+	Name       string  `json:"name"`
+	Body       string  `json:"body"`
+	Draft      bool    `json:"draft"`
+	Prerelease bool    `json:"prerelease"`
+	HTMLURL    string  `json:"html_url"`
+	UploadURL  string  `json:"upload_url"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// ReleaseRequest describes a release to create for an existing tag.
+type ReleaseRequest struct {
+	TagName    string `json:"tag_name"`
+	Name       string `json:"name,omitempty"`
+	Body       string `json:"body,omitempty"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// CreateRelease creates a release. The token needs write access to the
+// repository's contents.
+func (c *Client) CreateRelease(ctx context.Context, r ReleaseRequest) (*Release, error) {
+	var release Release
+	path := fmt.Sprintf("/repos/%s/%s/releases", c.owner, c.repo)
+	if err := c.do(ctx, http.MethodPost, path, r, &release); err != nil {
+		return nil, fmt.Errorf("failed to create release %s: %w", r.TagName, err)
+	}
+	return &release, nil
+}
+
+// GetReleaseByTag returns the release of tag, or nil if it has none.
+func (c *Client) GetReleaseByTag(ctx context.Context, tag string) (*Release, error) {
+	var release Release
+	path := fmt.Sprintf("/repos/%s/%s/releases/tags/%s", c.owner, c.repo, url.PathEscape(tag))
+	if err := c.do(ctx, http.MethodGet, path, nil, &release); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get release %s: %w", tag, err)
+	}
+	return &release, nil
+}
+
+// UploadAsset attaches the file at path to release, named after its base name.
+func (c *Client) UploadAsset(ctx context.Context, release *Release, path string) (*Asset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open asset: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat asset: %w", err)
+	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("asset %s is a directory", path)
+	}
+
+	name := filepath.Base(path)
+	// upload_url is a URI template like https://uploads.github.com/.../assets{?name,label}
+	uploadURL, _, _ := strings.Cut(release.UploadURL, "{")
+	if uploadURL == "" {
+		return nil, fmt.Errorf("release %s has no upload URL", release.TagName)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL+"?name="+url.QueryEscape(name), f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = fi.Size()
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var asset Asset
+	if err := c.send(req, contentType, uploadTimeout, &asset); err != nil {
+		return nil, fmt.Errorf("failed to upload asset %s: %w", name, err)
+	}
+	return &asset, nil
 }
@@ -0,0 +1,244 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreateRelease(t *testing.T) {
+	var got ReleaseRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/org/repo/releases" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Release{ID: 1, TagName: got.TagName, Prerelease: got.Prerelease})
+	}))
+	defer srv.Close()
+
+	c := NewClient("secret", "org", "repo").WithBaseURL(srv.URL + "/")
+	release, err := c.CreateRelease(context.Background(), ReleaseRequest{TagName: "v1", Body: "notes", Prerelease: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TagName != "v1" || got.Body != "notes" || !got.Prerelease {
+		t.Errorf("request body = %+v", got)
+	}
+	if release.ID != 1 || !release.Prerelease {
+		t.Errorf("release = %+v", release)
+	}
+}
+
+func TestGetReleaseByTag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/repo/releases/tags/v1":
+			json.NewEncoder(w).Encode(Release{ID: 1, TagName: "v1"})
+		case "/repos/org/repo/releases/tags/broken":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient("", "org", "repo").WithBaseURL(srv.URL)
+	ctx := context.Background()
+
+	if release, err := c.GetReleaseByTag(ctx, "v1"); err != nil || release == nil || release.ID != 1 {
+		t.Errorf("GetReleaseByTag(v1) = %+v, %v", release, err)
+	}
+	if release, err := c.GetReleaseByTag(ctx, "v2"); err != nil || release != nil {
+		t.Errorf("GetReleaseByTag(v2) = %+v, %v; want nil, nil", release, err)
+	}
+	if _, err := c.GetReleaseByTag(ctx, "broken"); err == nil {
+		t.Error("GetReleaseByTag(broken) succeeded on a server error")
+	}
+}
+
+func TestUploadAsset(t *testing.T) {
+	var name, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/upload/assets" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		name, contentType = r.URL.Query().Get("name"), r.Header.Get("Content-Type")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Asset{ID: 7, Name: name})
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "forklift-linux")
+	if err := os.WriteFile(path, []byte("binary"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient("secret", "org", "repo")
+	release := &Release{TagName: "v1", UploadURL: srv.URL + "/upload/assets{?name,label}"}
+	asset, err := c.UploadAsset(context.Background(), release, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ID != 7 || name != "forklift-linux" || body != "binary" {
+		t.Errorf("asset = %+v, name = %q, body = %q", asset, name, body)
+	}
+	if contentType != "application/octet-stream" {
+		t.Errorf("Content-Type = %q, want application/octet-stream", contentType)
+	}
+}
+
+func TestWorkflowRunsForTag(t *testing.T) {
+	type run struct {
+		ID         int64  `json:"id"`
+		Name       string `json:"name"`
+		Path       string `json:"path"`
+		HeadBranch string `json:"head_branch"`
+		Status     string `json:"status"`
+	}
+	// The first page only holds runs of the branch push of the same commit
+	var page1 []run
+	for i := range 100 {
+		page1 = append(page1, run{ID: int64(1000 - i), Name: "CI", Path: ".github/workflows/ci.yml", HeadBranch: "dev", Status: "completed"})
+	}
+	page2 := []run{
+		{ID: 3, Name: "CI", Path: ".github/workflows/ci.yml", HeadBranch: "v1", Status: "in_progress"},
+		{ID: 2, Name: "Release", Path: ".github/workflows/release.yml", HeadBranch: "v1", Status: "queued"},
+		{ID: 1, Name: "CI", Path: ".github/workflows/ci.yml", HeadBranch: "v1", Status: "completed"},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/repo/commits/v1":
+			json.NewEncoder(w).Encode(map[string]string{"sha": "abc123"})
+		case "/repos/org/repo/actions/runs":
+			q := r.URL.Query()
+			if q.Get("head_sha") != "abc123" || q.Get("per_page") != "100" {
+				t.Errorf("query = %s", r.URL.RawQuery)
+			}
+			runs := page1
+			if q.Get("page") == "2" {
+				runs = page2
+			}
+			json.NewEncoder(w).Encode(map[string]any{"total_count": len(page1) + len(page2), "workflow_runs": runs})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient("", "org", "repo").WithBaseURL(srv.URL)
+	ctx := context.Background()
+
+	runs, err := c.WorkflowRunsForTag(ctx, "v1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].RunID != 3 || runs[1].RunID != 2 {
+		t.Fatalf("WorkflowRunsForTag() = %+v, want the newest run of CI and Release", runs)
+	}
+	if runs[0].Name != "CI" || runs[0].Status != "in_progress" {
+		t.Errorf("run = %+v", runs[0])
+	}
+
+	for _, workflow := range []string{"Release", "release.yml"} {
+		runs, err := c.WorkflowRunsForTag(ctx, "v1", workflow)
+		if err != nil || len(runs) != 1 || runs[0].RunID != 2 {
+			t.Errorf("WorkflowRunsForTag(%q) = %+v, %v, want the Release run", workflow, runs, err)
+		}
+	}
+
+	if _, err := c.WorkflowRunsForTag(ctx, "v2", ""); err == nil {
+		t.Error("WorkflowRunsForTag() of an unknown tag succeeded")
+	}
+}
+
+func TestJobsForRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/repo/actions/runs/7/jobs" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"total_count": 2, "jobs": [
+			{"id": 1, "name": "build", "status": "completed", "conclusion": "success",
+			 "started_at": "2024-05-01T10:00:00Z", "completed_at": "2024-05-01T10:01:30Z", "steps": []},
+			{"id": 2, "name": "test", "status": "completed", "conclusion": "failure",
+			 "started_at": "2024-05-01T10:00:00Z", "completed_at": "2024-05-01T10:05:00Z", "steps": [
+				{"number": 1, "name": "Set up job", "status": "completed", "conclusion": "success"},
+				{"number": 2, "name": "Run tests", "status": "completed", "conclusion": "failure"},
+				{"number": 3, "name": "Upload coverage", "status": "completed", "conclusion": "skipped"}]}]}`)
+	}))
+	defer srv.Close()
+	c := NewClient("", "org", "repo").WithBaseURL(srv.URL)
+
+	jobs, err := c.JobsForRun(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("JobsForRun() = %+v, want 2 jobs", jobs)
+	}
+	if d := jobs[0].Duration(); d != 90*time.Second {
+		t.Errorf("build duration = %s, want 1m30s", d)
+	}
+	if step := jobs[0].FailedStep(); step != nil {
+		t.Errorf("build FailedStep() = %+v, want nil", step)
+	}
+	if step := jobs[1].FailedStep(); step == nil || step.Number != 2 || step.Name != "Run tests" {
+		t.Errorf("test FailedStep() = %+v, want step 2", step)
+	}
+	if _, err := c.JobsForRun(context.Background(), 8); err == nil {
+		t.Error("JobsForRun() of an unknown run succeeded")
+	}
+}
+
+func TestRunActions(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/repos/org/repo/actions/runs/8/cancel" {
+			http.Error(w, `{"message": "Cannot cancel a workflow run that is completed."}`, http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "{}")
+	}))
+	defer srv.Close()
+	c := NewClient("", "org", "repo").WithBaseURL(srv.URL)
+	ctx := context.Background()
+
+	if err := c.RerunRun(ctx, 7); err != nil {
+		t.Errorf("RerunRun() error = %v", err)
+	}
+	if err := c.RerunFailedJobs(ctx, 7); err != nil {
+		t.Errorf("RerunFailedJobs() error = %v", err)
+	}
+	if err := c.CancelRun(ctx, 7); err != nil {
+		t.Errorf("CancelRun() error = %v", err)
+	}
+	var apiErr *APIError
+	if err := c.CancelRun(ctx, 8); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("CancelRun() of a completed run error = %v, want status 409", err)
+	}
+
+	want := []string{
+		"POST /repos/org/repo/actions/runs/7/rerun",
+		"POST /repos/org/repo/actions/runs/7/rerun-failed-jobs",
+		"POST /repos/org/repo/actions/runs/7/cancel",
+		"POST /repos/org/repo/actions/runs/8/cancel",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package github
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RunLogs is the log archive of a workflow run. It holds a log per job and,
+// for most jobs, a log per step in a directory named after the job.
+type RunLogs struct {
+	zip *zip.Reader
+}
+
+// DownloadRunLogs downloads and opens the log archive of a completed workflow
+// run. The API redirects to a short-lived download URL, which is followed
+// without the token.
+func (c *Client) DownloadRunLogs(ctx context.Context, runID int64) (*RunLogs, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/actions/runs/%d/logs", c.owner, c.repo, runID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	var data []byte
+	if err := c.send(req, "", downloadTimeout, &data); err != nil {
+		return nil, fmt.Errorf("failed to download logs of run %d: %w", runID, err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open logs of run %d: %w", runID, err)
+	}
+	return &RunLogs{zip: zr}, nil
+}
+
+// timestamp matches the time GitHub puts in front of every log line.
+var timestamp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?Z `)
+
+// StepLog returns the output of a step of job, without timestamps. Archives
+// without step logs fall back to the log of the whole job.
+func (l *RunLogs) StepLog(job Job, step Step) ([]string, error) {
+	dir := logName(job.Name)
+	var jobLog *zip.File
+	for _, f := range l.zip.File {
+		name := strings.TrimSuffix(f.Name, ".txt")
+		d, base := path.Split(name)
+		number, title, ok := strings.Cut(base, "_")
+		if !ok {
+			continue
+		}
+		switch n, _ := strconv.Atoi(number); {
+		case d != "" && logName(strings.TrimSuffix(d, "/")) == dir && n == step.Number:
+			return readLines(f)
+		case d == "" && logName(title) == dir:
+			jobLog = f
+		}
+	}
+	if jobLog != nil {
+		return readLines(jobLog)
+	}
+	return nil, fmt.Errorf("no log of job %q in the archive", job.Name)
+}
+
+// logName reduces a job name to what survives in archive file names, which
+// drop characters such as / and :.
+func logName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func readLines(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		lines = append(lines, timestamp.ReplaceAllString(line, ""))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,84 @@
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func logArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadRunLogs(t *testing.T) {
+	archive := logArchive(t, map[string]string{
+		"0_build.txt":                       "2024-05-01T10:00:00.1234567Z whole build log\n",
+		"1_test (ubuntu-latest).txt":        "\ufeff2024-05-01T10:00:00.1234567Z whole test log\n",
+		"build/1_Set up job.txt":            "2024-05-01T10:00:00.1234567Z setting up\n",
+		"build/2_Run go build.txt":          "2024-05-01T10:00:01.0000000Z ##[group]Run go build\n2024-05-01T10:00:02.0000000Z ##[error]main.go:3: undefined: x\n",
+		"test (ubuntu-latest)/1_Set up.txt": "setting up tests\n",
+	})
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/repos/org/repo/actions/runs/7/logs":
+			http.Redirect(w, r, "/download/7.zip", http.StatusFound)
+		case "/download/7.zip":
+			w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient("secret", "org", "repo").WithBaseURL(srv.URL)
+
+	logs, err := c.DownloadRunLogs(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auth) != 2 || auth[0] != "Bearer secret" {
+		t.Errorf("Authorization headers = %q", auth)
+	}
+
+	tests := []struct {
+		job  string
+		step int
+		want []string
+	}{
+		{"build", 2, []string{"##[group]Run go build", "##[error]main.go:3: undefined: x"}},
+		{"build", 5, []string{"whole build log"}},
+		{"test (ubuntu-latest)", 1, []string{"setting up tests"}},
+		{"test (ubuntu-latest)", 2, []string{"whole test log"}},
+	}
+	for _, tt := range tests {
+		got, err := logs.StepLog(Job{Name: tt.job}, Step{Number: tt.step})
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StepLog(%s, %d) = %q, %v, want %q", tt.job, tt.step, got, err, tt.want)
+		}
+	}
+	if _, err := logs.StepLog(Job{Name: "deploy"}, Step{Number: 1}); err == nil {
+		t.Error("StepLog() of a job without logs succeeded")
+	}
+
+	if _, err := c.DownloadRunLogs(context.Background(), 8); err == nil {
+		t.Error("DownloadRunLogs() of an unknown run succeeded")
+	}
+}
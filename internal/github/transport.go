@@ -0,0 +1,267 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxCachedResponses bounds the ETag cache; it is emptied when full.
+const maxCachedResponses = 256
+
+// Transport is the http.RoundTripper of GitHub clients. It makes conditional
+// requests with the ETags of earlier responses, so unchanged resources cost
+// no quota, keeps track of the rate limit, waits out secondary rate limits
+// and retries server errors with jittered exponential backoff.
+type Transport struct {
+	base       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration // backoff before the first retry, doubled for each further one
+	maxWait    time.Duration // longest Retry-After to wait out
+
+	mu       sync.Mutex
+	cache    map[string]cachedResponse
+	limit    RateLimit
+	requests int
+}
+
+type cachedResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// NewTransport returns a Transport sending requests through base, or
+// http.DefaultTransport if base is nil.
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:       base,
+		maxRetries: 3,
+		baseDelay:  time.Second,
+		maxWait:    time.Minute,
+		cache:      make(map[string]cachedResponse),
+	}
+}
+
+// DefaultTransport is shared by the clients of NewClient, so they share the
+// ETag cache and the rate limit.
+var DefaultTransport = NewTransport(nil)
+
+// RateLimit is the state of the primary rate limit, as of the last response.
+type RateLimit struct {
+	Limit     int // requests per window, 0 if unknown
+	Remaining int
+	Reset     time.Time
+}
+
+// PollInterval returns how long to wait between polls of perPoll requests:
+// base, or longer if polling every base would use up the quota before it
+// resets. A tenth of the quota is left for other commands.
+func (r RateLimit) PollInterval(base time.Duration, perPoll int, now time.Time) time.Duration {
+	untilReset := r.Reset.Sub(now)
+	if r.Limit == 0 || perPoll <= 0 || untilReset <= 0 {
+		return base
+	}
+	polls := (r.Remaining - r.Limit/10) / perPoll
+	if polls <= 0 {
+		return max(base, untilReset+time.Second)
+	}
+	return max(base, untilReset/time.Duration(polls))
+}
+
+// RateLimit returns the rate limit as of the last response.
+func (t *Transport) RateLimit() RateLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// Requests returns the number of requests sent so far, retries included.
+func (t *Transport) Requests() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.requests
+}
+
+// timeoutKey is the context key of the per-attempt timeout set by
+// withAttemptTimeout.
+type timeoutKey struct{}
+
+// withAttemptTimeout makes the Transport bound each attempt of a request,
+// including reading its response, by d. Waits between attempts don't count.
+func withAttemptTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, cached, ok := "", cachedResponse{}, false
+	if req.Method == http.MethodGet && req.Header.Get("If-None-Match") == "" {
+		key = req.URL.String() + " " + req.Header.Get("Accept")
+		t.mu.Lock()
+		cached, ok = t.cache[key]
+		t.mu.Unlock()
+		if ok {
+			req = req.Clone(req.Context())
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+	}
+
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		var err error
+		if resp, err = t.attempt(req); err != nil {
+			return nil, err
+		}
+		t.record(resp)
+
+		wait, retry := t.retryDelay(req, resp, attempt)
+		if !retry {
+			break
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotModified && ok {
+		resp.Body.Close()
+		header := cached.header.Clone()
+		for _, h := range []string{"X-Ratelimit-Limit", "X-Ratelimit-Remaining", "X-Ratelimit-Reset"} {
+			if v := resp.Header.Get(h); v != "" {
+				header.Set(h, v)
+			}
+		}
+		resp.StatusCode, resp.Status = http.StatusOK, "200 OK"
+		resp.Header = header
+		resp.Body = io.NopCloser(bytes.NewReader(cached.body))
+		resp.ContentLength = int64(len(cached.body))
+		return resp, nil
+	}
+	if key != "" && resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" && isJSON(resp.Header) {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		if len(t.cache) >= maxCachedResponses {
+			clear(t.cache)
+		}
+		t.cache[key] = cachedResponse{etag: resp.Header.Get("ETag"), header: resp.Header.Clone(), body: body}
+		t.mu.Unlock()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
+
+// attempt sends req once, bounded by its attempt timeout if it has one.
+func (t *Transport) attempt(req *http.Request) (*http.Response, error) {
+	d, ok := req.Context().Value(timeoutKey{}).(time.Duration)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), d)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the context of its request when closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// record counts a request and updates the rate limit from its response.
+func (t *Transport) record(resp *http.Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests++
+	limit, err1 := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	remaining, err2 := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, err3 := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 == nil && err2 == nil && err3 == nil {
+		t.limit = RateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+	}
+}
+
+// retryDelay decides whether to retry req after resp, and how long to wait
+// first. Secondary rate limits are waited out as long as Retry-After asks,
+// up to maxWait; server errors of idempotent requests are retried with
+// backoff. An exhausted primary rate limit is not retried, as it can take up
+// to an hour to reset.
+func (t *Transport) retryDelay(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	if attempt >= t.maxRetries || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return 0, false
+	}
+
+	var wait time.Duration
+	switch code := resp.StatusCode; {
+	case code == http.StatusForbidden || code == http.StatusTooManyRequests:
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+		if wait > t.maxWait {
+			return 0, false
+		}
+	case code >= 500 && idempotent(req.Method):
+		d := t.baseDelay << attempt
+		wait = d/2 + rand.N(d/2+1)
+	default:
+		return 0, false
+	}
+
+	if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+		return 0, false
+	}
+	return wait, true
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isJSON(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "application/json"
+}
@@ -0,0 +1,150 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the retries of the tests' server errors fast
+	DefaultTransport.baseDelay = time.Millisecond
+	os.Exit(m.Run())
+}
+
+// testClient returns a client for srv with a transport of its own.
+func testClient(srv *httptest.Server) (*Client, *Transport) {
+	t := NewTransport(nil)
+	t.baseDelay = time.Millisecond
+	c := NewClient("", "org", "repo").WithBaseURL(srv.URL)
+	c.http = &http.Client{Transport: t}
+	return c, t
+}
+
+func TestTransportETag(t *testing.T) {
+	hits, notModified := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(60-hits))
+		w.Header().Set("X-RateLimit-Reset", "1714557600")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"sha": "abc123"}`))
+	}))
+	defer srv.Close()
+	c, tr := testClient(srv)
+
+	for range 3 {
+		sha, err := c.CommitSHA(context.Background(), "v1")
+		if err != nil || sha != "abc123" {
+			t.Fatalf("CommitSHA() = %q, %v", sha, err)
+		}
+	}
+	if hits != 3 || notModified != 2 {
+		t.Errorf("requests = %d with %d not modified, want 3 with 2", hits, notModified)
+	}
+	want := RateLimit{Limit: 60, Remaining: 57, Reset: time.Unix(1714557600, 0)}
+	if got := c.RateLimit(); got != want {
+		t.Errorf("RateLimit() = %+v, want %+v", got, want)
+	}
+	if tr.Requests() != 3 {
+		t.Errorf("Requests() = %d, want 3", tr.Requests())
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+	hits := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.Method+" "+r.URL.Path]++
+		n := hits[r.Method+" "+r.URL.Path]
+		switch r.URL.Path {
+		case "/repos/org/repo/commits/flaky":
+			if n <= 2 {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`{"sha": "abc123"}`))
+		case "/repos/org/repo/commits/down":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case "/repos/org/repo/commits/secondary":
+			if n == 1 {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, "secondary rate limit", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"sha": "abc123"}`))
+		case "/repos/org/repo/commits/exhausted":
+			w.Header().Set("Retry-After", "0")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			http.Error(w, "rate limit exceeded", http.StatusForbidden)
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	c, _ := testClient(srv)
+	ctx := context.Background()
+
+	if sha, err := c.CommitSHA(ctx, "flaky"); err != nil || sha != "abc123" {
+		t.Errorf("CommitSHA() after server errors = %q, %v", sha, err)
+	}
+	var apiErr *APIError
+	if _, err := c.CommitSHA(ctx, "down"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("CommitSHA() of a down server error = %v", err)
+	}
+	if sha, err := c.CommitSHA(ctx, "secondary"); err != nil || sha != "abc123" {
+		t.Errorf("CommitSHA() after a secondary rate limit = %q, %v", sha, err)
+	}
+	if _, err := c.CommitSHA(ctx, "exhausted"); err == nil {
+		t.Error("CommitSHA() with an exhausted rate limit succeeded")
+	}
+	if err := c.CancelRun(ctx, 7); err == nil {
+		t.Error("CancelRun() on a server error succeeded")
+	}
+
+	want := map[string]int{
+		"GET /repos/org/repo/commits/flaky":          3,
+		"GET /repos/org/repo/commits/down":           4,
+		"GET /repos/org/repo/commits/secondary":      2,
+		"GET /repos/org/repo/commits/exhausted":      1,
+		"POST /repos/org/repo/actions/runs/7/cancel": 1,
+	}
+	for req, n := range want {
+		if hits[req] != n {
+			t.Errorf("%s sent %d times, want %d", req, hits[req], n)
+		}
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	now := time.Now()
+	base := 30 * time.Second
+	tests := []struct {
+		name    string
+		limit   RateLimit
+		perPoll int
+		want    time.Duration
+	}{
+		{"unknown limit", RateLimit{}, 3, base},
+		{"plenty left", RateLimit{Limit: 5000, Remaining: 4000, Reset: now.Add(time.Hour)}, 3, base},
+		{"running low", RateLimit{Limit: 60, Remaining: 36, Reset: now.Add(30 * time.Minute)}, 3, 3 * time.Minute},
+		{"used up", RateLimit{Limit: 60, Remaining: 5, Reset: now.Add(10 * time.Minute)}, 3, 10*time.Minute + time.Second},
+		{"reset passed", RateLimit{Limit: 60, Remaining: 0, Reset: now.Add(-time.Minute)}, 3, base},
+	}
+	for _, tt := range tests {
+		if got := tt.limit.PollInterval(base, tt.perPoll, now); got != tt.want {
+			t.Errorf("%s: PollInterval() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package hooks runs the commands a repository configures for the phases of
+// a build, such as tests after the merge or a chat message after tagging.
+package hooks
+
+import (
+	"fmt"
+	"forklift/internal/repoconfig"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Hook phases, in the order they run.
+const (
+	PreMerge  = "pre-merge"  // on the freshly pulled merge branch, before merging
+	PostMerge = "post-merge" // on the merged code, before anything is pushed
+	PrePush   = "pre-push"   // right before the merge branch is pushed
+	PostTag   = "post-tag"   // after the tag was pushed and the backend updated
+	OnFailure = "on-failure" // when the build fails
+)
+
+// Phases lists the hook phases in the order they run.
+var Phases = []string{PreMerge, PostMerge, PrePush, PostTag, OnFailure}
+
+// Dir holds hook scripts, relative to the repository root. A phase runs the
+// executable named after it, or every executable in the directory named
+// after it, in name order.
+const Dir = ".forklift/hooks"
+
+// Hook is one command of a phase.
+type Hook struct {
+	// Name is the command line, or the script path relative to the repository root.
+	Name string
+	args []string
+}
+
+// Set holds the hooks of a repository by phase.
+type Set map[string][]Hook
+
+// Load collects the hooks of the repository at root: the commands listed in
+// .forklift.yaml, followed by the scripts in Dir.
+func Load(root string) (Set, error) {
+	cfg, err := repoconfig.Load(root)
+	if err != nil {
+		return nil, err
+	}
+	return FromConfig(root, cfg.Hooks)
+}
+
+// FromConfig builds the hooks from the commands per phase, as listed in
+// .forklift.yaml, followed by the scripts in Dir below root.
+func FromConfig(root string, commands map[string][]string) (Set, error) {
+	set := make(Set)
+	for phase, cmds := range commands {
+		if !slices.Contains(Phases, phase) {
+			return nil, fmt.Errorf("unknown hook phase %q in %s, want one of %s", phase, repoconfig.FileName, strings.Join(Phases, ", "))
+		}
+		for _, cmd := range cmds {
+			set[phase] = append(set[phase], Hook{Name: cmd, args: shell(cmd)})
+		}
+	}
+
+	for _, phase := range Phases {
+		scripts, err := scripts(filepath.Join(root, Dir, phase))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range scripts {
+			rel, _ := filepath.Rel(root, path)
+			set[phase] = append(set[phase], Hook{Name: rel, args: []string{path}})
+		}
+	}
+	return set, nil
+}
+
+// scripts returns path if it is a script, or the scripts in it if it is a
+// directory.
+func scripts(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if fi.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		paths = nil
+		for _, e := range entries {
+			if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+				paths = append(paths, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	for _, p := range paths {
+		if err := checkExecutable(p); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+func checkExecutable(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&0111 == 0 {
+		return fmt.Errorf("hook %s is not executable; run chmod +x on it", path)
+	}
+	return nil
+}
+
+func shell(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+// Context describes the build to its hooks. They receive it as environment
+// variables, see Env.
+type Context struct {
+	Repo         string
+	SourceBranch string
+	MergeBranch  string
+	Strategy     string
+	Tag          string // empty before the tag is chosen
+	PreviousTag  string
+	Commit       string // HEAD of the merge branch
+	Error        string // why the build failed, for on-failure hooks
+}
+
+// Env returns the environment variables passed to the hooks of phase.
+func (c Context) Env(phase string) []string {
+	return []string{
+		"FORKLIFT_PHASE=" + phase,
+		"FORKLIFT_REPO=" + c.Repo,
+		"FORKLIFT_SOURCE_BRANCH=" + c.SourceBranch,
+		"FORKLIFT_MERGE_BRANCH=" + c.MergeBranch,
+		"FORKLIFT_STRATEGY=" + c.Strategy,
+		"FORKLIFT_TAG=" + c.Tag,
+		"FORKLIFT_PREVIOUS_TAG=" + c.PreviousTag,
+		"FORKLIFT_COMMIT=" + c.Commit,
+		"FORKLIFT_ERROR=" + c.Error,
+	}
+}
+
+// Run runs the hooks of phase in dir, one after another, with their output
+// going to the terminal. It stops at the first hook that fails.
+func (s Set) Run(phase, dir string, c Context) error {
+	env := append(os.Environ(), c.Env(phase)...)
+	for _, h := range s[phase] {
+		fmt.Printf("🪝 %s: %s\n", phase, h.Name)
+		cmd := exec.Command(h.args[0], h.args[1:]...)
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", phase, h.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package hooks
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func names(hooks []Hook) []string {
+	var out []string
+	for _, h := range hooks {
+		out = append(out, h.Name)
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".forklift.yaml"), "hooks:\n  post-merge:\n    - go test ./...\n    - go vet ./...\n", 0644)
+	writeFile(t, filepath.Join(root, Dir, "pre-push"), "#!/bin/sh\n", 0755)
+	writeFile(t, filepath.Join(root, Dir, "post-tag", "20-chat"), "#!/bin/sh\n", 0755)
+	writeFile(t, filepath.Join(root, Dir, "post-tag", "10-mail"), "#!/bin/sh\n", 0755)
+
+	set, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		PostMerge: {"go test ./...", "go vet ./..."},
+		PrePush:   {filepath.Join(Dir, "pre-push")},
+		PostTag:   {filepath.Join(Dir, "post-tag", "10-mail"), filepath.Join(Dir, "post-tag", "20-chat")},
+	}
+	for _, phase := range Phases {
+		if got := names(set[phase]); !reflect.DeepEqual(got, want[phase]) {
+			t.Errorf("%s hooks = %v, want %v", phase, got, want[phase])
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"unknown phase":  {".forklift.yaml": "hooks:\n  post-commit: [true]\n"},
+		"unknown key":    {".forklift.yaml": "hook:\n  post-merge: [true]\n"},
+		"not executable": {filepath.Join(Dir, "pre-merge"): "#!/bin/sh\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			for path, content := range files {
+				writeFile(t, filepath.Join(root, path), content, 0644)
+			}
+			if _, err := Load(root); err == nil {
+				t.Error("Load() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	set, err := FromConfig(dir, map[string][]string{
+		PostTag: {
+			`echo "$FORKLIFT_PHASE $FORKLIFT_REPO $FORKLIFT_TAG $FORKLIFT_SOURCE_BRANCH->$FORKLIFT_MERGE_BRANCH" > out`,
+			"exit 3",
+			"echo unreachable >> out",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = set.Run(PostTag, dir, Context{Repo: "org/repo", SourceBranch: "feature", MergeBranch: "dev", Tag: "v-dev-0.0.1"})
+	if err == nil || !strings.Contains(err.Error(), `post-tag hook "exit 3" failed`) {
+		t.Errorf("Run() error = %v, want failure of the second hook", err)
+	}
+	data, _ := os.ReadFile(out)
+	if got, want := string(data), "post-tag org/repo v-dev-0.0.1 feature->dev\n"; got != want {
+		t.Errorf("hook output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+// Package notes generates release notes from the commits between two tags,
+// grouped by Conventional Commit type.
+package notes
+
+import (
+	"encoding/json"
+	"fmt"
+	"forklift/internal/git"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Output formats.
+const (
+	Markdown = "markdown"
+	JSON     = "json"
+)
+
+// Entry is one commit in the notes.
+type Entry struct {
+	Type        string `json:"type"`
+	Scope       string `json:"scope,omitempty"`
+	Description string `json:"description"`
+	Breaking    bool   `json:"breaking,omitempty"`
+	SHA         string `json:"sha"`
+	PRs         []int  `json:"prs,omitempty"`
+}
+
+// Group holds the entries of one commit type.
+type Group struct {
+	Type    string  `json:"type"`
+	Title   string  `json:"title"`
+	Entries []Entry `json:"entries"`
+}
+
+// Notes are the release notes of a tag.
+type Notes struct {
+	Tag         string  `json:"tag"`
+	PreviousTag string  `json:"previous_tag,omitempty"`
+	Groups      []Group `json:"groups"`
+	Breaking    []Entry `json:"breaking,omitempty"`
+	PRs         []int   `json:"prs"`
+}
+
+// OtherType groups commits that don't follow Conventional Commits.
+const OtherType = "other"
+
+// groupTitles lists the commit types in the order they are rendered.
+var groupTitles = []struct{ typ, title string }{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "CI"},
+	{"chore", "Chores"},
+	{"style", "Style"},
+	{OtherType, "Other Changes"},
+}
+
+var (
+	conventionalPattern = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+	// (#123) as appended by squash merges, or "Merge pull request #123"
+	prPattern          = regexp.MustCompile(`(?:\(#(\d+)\)|^Merge pull request #(\d+))`)
+	mergeCommitPattern = regexp.MustCompile(`^Merge (?:pull request|branch|remote-tracking branch) `)
+)
+
+// Generate builds the notes of tag from the commits since previousTag,
+// newest first as git log returns them. Merge commits only contribute the
+// pull request numbers they name.
+func Generate(tag, previousTag string, commits []git.Commit) *Notes {
+	n := &Notes{Tag: tag, PreviousTag: previousTag, PRs: []int{}}
+	byType := make(map[string][]Entry)
+	for _, c := range commits {
+		prs := pullRequests(c.Subject)
+		for _, pr := range prs {
+			if !slices.Contains(n.PRs, pr) {
+				n.PRs = append(n.PRs, pr)
+			}
+		}
+		if mergeCommitPattern.MatchString(c.Subject) {
+			continue
+		}
+
+		e := parse(c.Subject)
+		e.SHA = c.SHA
+		e.PRs = prs
+		if e.Breaking {
+			n.Breaking = append(n.Breaking, e)
+		}
+		byType[e.Type] = append(byType[e.Type], e)
+	}
+	slices.Sort(n.PRs)
+
+	n.Groups = []Group{}
+	for _, g := range groupTitles {
+		if entries := byType[g.typ]; len(entries) > 0 {
+			n.Groups = append(n.Groups, Group{Type: g.typ, Title: g.title, Entries: entries})
+		}
+	}
+	return n
+}
+
+// parse splits a commit subject into its Conventional Commit parts. Subjects
+// with an unknown type go to OtherType.
+func parse(subject string) Entry {
+	m := conventionalPattern.FindStringSubmatch(subject)
+	if m == nil {
+		return Entry{Type: OtherType, Description: subject}
+	}
+	typ := strings.ToLower(m[1])
+	known := slices.ContainsFunc(groupTitles, func(g struct{ typ, title string }) bool { return g.typ == typ })
+	if !known || typ == OtherType {
+		return Entry{Type: OtherType, Description: subject}
+	}
+	return Entry{Type: typ, Scope: m[2], Breaking: m[3] == "!", Description: m[4]}
+}
+
+func pullRequests(subject string) []int {
+	var prs []int
+	for _, m := range prPattern.FindAllStringSubmatch(subject, -1) {
+		num := m[1]
+		if num == "" {
+			num = m[2]
+		}
+		if pr, err := strconv.Atoi(num); err == nil {
+			prs = append(prs, pr)
+		}
+	}
+	return prs
+}
+
+// Render formats the notes as Markdown or JSON.
+func (n *Notes) Render(format string) (string, error) {
+	switch format {
+	case "", Markdown:
+		return n.Markdown(), nil
+	case JSON:
+		data, err := json.MarshalIndent(n, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	default:
+		return "", fmt.Errorf("unknown notes format %q, want %s or %s", format, Markdown, JSON)
+	}
+}
+
+// Markdown renders the notes as a Markdown document.
+func (n *Notes) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", n.Tag)
+	if n.PreviousTag != "" {
+		fmt.Fprintf(&b, "Changes since %s.\n\n", n.PreviousTag)
+	}
+	if len(n.Groups) == 0 && len(n.PRs) == 0 {
+		b.WriteString("No changes.\n")
+		return b.String()
+	}
+
+	if len(n.Breaking) > 0 {
+		b.WriteString("### ⚠ Breaking Changes\n\n")
+		for _, e := range n.Breaking {
+			writeEntry(&b, e)
+		}
+		b.WriteString("\n")
+	}
+	for _, g := range n.Groups {
+		fmt.Fprintf(&b, "### %s\n\n", g.Title)
+		for _, e := range g.Entries {
+			writeEntry(&b, e)
+		}
+		b.WriteString("\n")
+	}
+	if len(n.PRs) > 0 {
+		refs := make([]string, len(n.PRs))
+		for i, pr := range n.PRs {
+			refs[i] = fmt.Sprintf("#%d", pr)
+		}
+		fmt.Fprintf(&b, "Pull requests: %s\n", strings.Join(refs, ", "))
+	}
+	return b.String()
+}
+
+func writeEntry(b *strings.Builder, e Entry) {
+	b.WriteString("- ")
+	if e.Scope != "" {
+		fmt.Fprintf(b, "**%s:** ", e.Scope)
+	}
+	fmt.Fprintf(b, "%s (%s)\n", e.Description, e.SHA)
+}
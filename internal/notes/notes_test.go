@@ -0,0 +1,94 @@
+package notes
+
+import (
+	"encoding/json"
+	"forklift/internal/git"
+	"reflect"
+	"testing"
+)
+
+var commits = []git.Commit{
+	{SHA: "a1", Subject: "Merge pull request #42 from org/feature-login"},
+	{SHA: "b2", Subject: "feat(auth): add SSO login (#41)"},
+	{SHA: "c3", Subject: "fix: handle empty config"},
+	{SHA: "d4", Subject: "feat!: drop the v1 API"},
+	{SHA: "e5", Subject: "Update README"},
+	{SHA: "f6", Subject: "Merge branch 'dev' into feature-login"},
+	{SHA: "g7", Subject: "wip: experiments"},
+}
+
+func TestGenerate(t *testing.T) {
+	n := Generate("v-dev-0.2.0", "v-dev-0.1.0", commits)
+
+	var got []string
+	for _, g := range n.Groups {
+		for _, e := range g.Entries {
+			got = append(got, g.Type+" "+e.SHA)
+		}
+	}
+	want := []string{"feat b2", "feat d4", "fix c3", "other e5", "other g7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("grouped entries = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(n.PRs, []int{41, 42}) {
+		t.Errorf("PRs = %v, want [41 42]", n.PRs)
+	}
+	if len(n.Breaking) != 1 || n.Breaking[0].Description != "drop the v1 API" {
+		t.Errorf("Breaking = %+v", n.Breaking)
+	}
+	if e := n.Groups[0].Entries[0]; e.Scope != "auth" || e.Description != "add SSO login (#41)" || !reflect.DeepEqual(e.PRs, []int{41}) {
+		t.Errorf("first feature = %+v", e)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	got := Generate("v-dev-0.2.0", "v-dev-0.1.0", commits[:4]).Markdown()
+	want := `## v-dev-0.2.0
+
+Changes since v-dev-0.1.0.
+
+### ⚠ Breaking Changes
+
+- drop the v1 API (d4)
+
+### Features
+
+- **auth:** add SSO login (#41) (b2)
+- drop the v1 API (d4)
+
+### Bug Fixes
+
+- handle empty config (c3)
+
+Pull requests: #41, #42
+`
+	if got != want {
+		t.Errorf("Markdown() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Generate("v-dev-0.0.1", "", nil).Markdown(); got != "## v-dev-0.0.1\n\nNo changes.\n" {
+		t.Errorf("empty Markdown() = %q", got)
+	}
+
+	// Merge commits have no group, but their pull requests are still listed
+	if got := Generate("v-dev-0.0.2", "", commits[:1]).Markdown(); got != "## v-dev-0.0.2\n\nPull requests: #42\n" {
+		t.Errorf("Markdown() of merge commits only = %q", got)
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	out, err := Generate("v-dev-0.2.0", "", commits).Render(JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Notes
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("Render(JSON) is not valid JSON: %v", err)
+	}
+	if decoded.Tag != "v-dev-0.2.0" || len(decoded.Groups) != 3 {
+		t.Errorf("decoded = %+v", decoded)
+	}
+	if _, err := Generate("x", "", nil).Render("html"); err == nil {
+		t.Error("Render(html) succeeded, want error")
+	}
+}
@@ -1,7 +1,11 @@
 package notification
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gen2brain/beeep"
 )
@@ -17,3 +21,41 @@ func Send(title, message string) error {
 	}
 	return nil
 }
+
+// Targets are the places a notification goes to.
+type Targets struct {
+	Desktop  bool
+	Webhooks []string // receive a JSON POST with a "text" field
+}
+
+// Notify sends the notification to every target. Failing targets are
+// reported but don't stop the others.
+func (t Targets) Notify(title, message string) {
+	if t.Desktop {
+		Send(title, message)
+	}
+	for _, url := range t.Webhooks {
+		if err := PostWebhook(url, title+": "+message); err != nil {
+			fmt.Printf("Warning: failed to notify webhook: %v\n", err)
+		}
+	}
+}
+
+// PostWebhook posts text to a chat webhook, in the {"text": ...} format
+// Slack, Mattermost, Rocket.Chat and Google Chat accept.
+func PostWebhook(url, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Package repoconfig loads .forklift.yaml, the forklift settings a repository
+// versions alongside its code.
+package repoconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the repo config file, in the repository root.
+const FileName = ".forklift.yaml"
+
+// Config is the content of .forklift.yaml. Empty fields leave the setting to
+// the backend, the user config or the default.
+type Config struct {
+	// TagTemplate is the tag template of the repo, see package tagscheme.
+	TagTemplate string `yaml:"tag_template,omitempty"`
+	// TagType is lightweight, annotated or signed.
+	TagType string `yaml:"tag_type,omitempty"`
+	// MergeBranches restricts the merge branches the repo may use. Entries
+	// may be glob patterns such as release/*. Empty allows any branch.
+	MergeBranches []string `yaml:"merge_branches,omitempty"`
+	// Strategy is the merge strategy: merge, squash, rebase or ff-only.
+	Strategy string `yaml:"strategy,omitempty"`
+	// Workflow is the name or file name of the GitHub Actions workflow to poll.
+	Workflow string `yaml:"workflow,omitempty"`
+	// Notify configures where finished workflow runs are announced.
+	Notify Notify `yaml:"notify,omitempty"`
+	// Hooks lists shell commands to run per hook phase, e.g. post-merge.
+	Hooks map[string][]string `yaml:"hooks,omitempty"`
+}
+
+// Notify lists notification targets.
+type Notify struct {
+	// Desktop turns desktop notifications on or off; nil leaves them on.
+	Desktop *bool `yaml:"desktop,omitempty"`
+	// Webhooks receive a JSON POST with a "text" field, as Slack and most
+	// chat tools accept.
+	Webhooks []string `yaml:"webhooks,omitempty"`
+}
+
+// AllowsMergeBranch reports whether branch may be used as merge branch.
+func (c *Config) AllowsMergeBranch(branch string) bool {
+	if c == nil || len(c.MergeBranches) == 0 {
+		return true
+	}
+	for _, pattern := range c.MergeBranches {
+		if ok, _ := path.Match(pattern, branch); ok || pattern == branch {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads .forklift.yaml from the repository root dir. A missing file
+// gives an empty config. Unknown keys are errors, so typos don't go unnoticed.
+func Load(dir string) (*Config, error) {
+	file := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return &cfg, nil
+}
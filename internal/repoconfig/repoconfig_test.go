@@ -0,0 +1,36 @@
+package repoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := Load(dir)
+	if err != nil || cfg == nil {
+		t.Fatalf("Load() without file = %v, %v, want empty config", cfg, err)
+	}
+
+	file := filepath.Join(dir, FileName)
+	os.WriteFile(file, []byte("strategy: squash\nmerge_branches: [dev, release/*]\nnotify:\n  desktop: false\n"), 0644)
+	cfg, err = Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Strategy != "squash" || cfg.Notify.Desktop == nil || *cfg.Notify.Desktop {
+		t.Errorf("Load() = %+v", cfg)
+	}
+	for branch, want := range map[string]bool{"dev": true, "release/1.2": true, "main": false, "release/1/2": false} {
+		if got := cfg.AllowsMergeBranch(branch); got != want {
+			t.Errorf("AllowsMergeBranch(%q) = %v, want %v", branch, got, want)
+		}
+	}
+
+	os.WriteFile(file, []byte("stratgey: squash\n"), 0644)
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "stratgey") {
+		t.Errorf("Load() with unknown key error = %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package settings merges forklift's configuration sources into the effective
+// settings of a repository and remembers where each value came from.
+//
+// Precedence, highest first: command-line flags, the repository's
+// .forklift.yaml, the repo's row in the backend, the user config, defaults.
+package settings
+
+import (
+	"fmt"
+	"forklift/internal/build"
+	"forklift/internal/notes"
+	"forklift/internal/repoconfig"
+	"forklift/internal/structures"
+	"forklift/internal/tagscheme"
+	"strings"
+)
+
+// Source names where a value came from.
+type Source string
+
+// Sources, lowest precedence first.
+const (
+	SourceDefault Source = "default"
+	SourceUser    Source = "user config"
+	SourceBackend Source = "backend"
+	SourceRepo    Source = repoconfig.FileName
+	SourceFlag    Source = "flag"
+)
+
+// Value is a setting together with its source.
+type Value[T any] struct {
+	Value  T
+	Source Source
+}
+
+// Set replaces the value, e.g. with a flag.
+func (v *Value[T]) Set(value T, src Source) {
+	v.Value, v.Source = value, src
+}
+
+// Layers are the configuration sources flags are applied on top of.
+type Layers struct {
+	User    structures.Config
+	Backend *structures.RepoInfo // nil if the repo is not in the backend
+	Repo    *repoconfig.Config   // nil if there is no .forklift.yaml
+}
+
+// Settings are the effective settings of a repository.
+type Settings struct {
+	MergeBranch    Value[string]
+	MergeBranches  Value[[]string] // allowed merge branches; empty allows any
+	TagTemplate    Value[string]
+	TagType        Value[string]
+	Strategy       Value[string]
+	Worktree       Value[bool]
+	ReleaseNotes   Value[string]
+	GitHubRelease  Value[bool]
+	ReleaseAssets  Value[[]string]
+	ProdBranches   Value[[]string]
+	Workflow       Value[string] // empty means any workflow the tag triggers
+	NotifyDesktop  Value[bool]
+	NotifyWebhooks Value[[]string]
+	PollInterval   Value[int] // seconds
+	PollTimeout    Value[int] // minutes
+}
+
+// Resolve merges the layers into the effective settings.
+func Resolve(l Layers) *Settings {
+	s := &Settings{
+		TagTemplate:   Value[string]{tagscheme.Default, SourceDefault},
+		TagType:       Value[string]{build.TagLightweight, SourceDefault},
+		Strategy:      Value[string]{build.StrategyMerge, SourceDefault},
+		Worktree:      Value[bool]{false, SourceDefault},
+		ReleaseNotes:  Value[string]{notes.Markdown, SourceDefault},
+		GitHubRelease: Value[bool]{false, SourceDefault},
+		ProdBranches:  Value[[]string]{build.DefaultProdBranches, SourceDefault},
+		NotifyDesktop: Value[bool]{true, SourceDefault},
+		PollInterval:  Value[int]{30, SourceDefault},
+		PollTimeout:   Value[int]{30, SourceDefault},
+	}
+
+	u := l.User
+	setString(&s.TagTemplate, u.TagTemplate, SourceUser)
+	setString(&s.TagType, u.TagType, SourceUser)
+	setBool(&s.Worktree, u.Worktree, SourceUser)
+	setString(&s.ReleaseNotes, u.ReleaseNotes, SourceUser)
+	setBool(&s.GitHubRelease, u.GitHubRelease, SourceUser)
+	setList(&s.ReleaseAssets, u.ReleaseAssets, SourceUser)
+	setList(&s.ProdBranches, u.ProdBranches, SourceUser)
+	setList(&s.NotifyWebhooks, u.NotifyWebhooks, SourceUser)
+	if u.PollInterval > 0 {
+		s.PollInterval.Set(u.PollInterval, SourceUser)
+	}
+	if u.PollTimeout > 0 {
+		s.PollTimeout.Set(u.PollTimeout, SourceUser)
+	}
+
+	if b := l.Backend; b != nil {
+		setString(&s.MergeBranch, b.MergeBranch, SourceBackend)
+		setString(&s.TagTemplate, b.TagTemplate, SourceBackend)
+		setString(&s.Strategy, strings.TrimSpace(b.MergeStrategy), SourceBackend)
+	}
+
+	if r := l.Repo; r != nil {
+		setList(&s.MergeBranches, r.MergeBranches, SourceRepo)
+		setString(&s.TagTemplate, r.TagTemplate, SourceRepo)
+		setString(&s.TagType, r.TagType, SourceRepo)
+		setString(&s.Strategy, r.Strategy, SourceRepo)
+		setString(&s.Workflow, r.Workflow, SourceRepo)
+		if r.Notify.Desktop != nil {
+			s.NotifyDesktop.Set(*r.Notify.Desktop, SourceRepo)
+		}
+		setList(&s.NotifyWebhooks, r.Notify.Webhooks, SourceRepo)
+	}
+	return s
+}
+
+func setString(v *Value[string], value string, src Source) {
+	if value != "" {
+		v.Set(value, src)
+	}
+}
+
+// setBool only applies true: the JSON user config can't tell false from unset.
+func setBool(v *Value[bool], value bool, src Source) {
+	if value {
+		v.Set(value, src)
+	}
+}
+
+func setList(v *Value[[]string], value []string, src Source) {
+	if len(value) > 0 {
+		v.Set(value, src)
+	}
+}
+
+// Entry is one setting as listed by Entries.
+type Entry struct {
+	Name   string
+	Value  string
+	Source Source
+}
+
+// Entries lists the settings in display order, named after their keys in
+// the config files. Unset values have an empty Source.
+func (s *Settings) Entries() []Entry {
+	return []Entry{
+		entry("merge_branch", s.MergeBranch),
+		entry("merge_branches", s.MergeBranches),
+		entry("tag_template", s.TagTemplate),
+		entry("tag_type", s.TagType),
+		entry("strategy", s.Strategy),
+		entry("worktree", s.Worktree),
+		entry("release_notes", s.ReleaseNotes),
+		entry("github_release", s.GitHubRelease),
+		entry("release_assets", s.ReleaseAssets),
+		entry("prod_branches", s.ProdBranches),
+		entry("workflow", s.Workflow),
+		entry("notify.desktop", s.NotifyDesktop),
+		entry("notify.webhooks", s.NotifyWebhooks),
+		entry("poll_interval", s.PollInterval),
+		entry("poll_timeout", s.PollTimeout),
+	}
+}
+
+func entry[T any](name string, v Value[T]) Entry {
+	value := fmt.Sprint(v.Value)
+	if list, ok := any(v.Value).([]string); ok {
+		value = strings.Join(list, ", ")
+	}
+	return Entry{Name: name, Value: value, Source: v.Source}
+}
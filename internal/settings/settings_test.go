@@ -0,0 +1,65 @@
+package settings
+
+import (
+	"forklift/internal/repoconfig"
+	"forklift/internal/structures"
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	off := false
+	s := Resolve(Layers{
+		User: structures.Config{
+			TagTemplate:    "v{major}.{minor}.{patch}",
+			TagType:        "annotated",
+			PollInterval:   10,
+			NotifyWebhooks: []string{"https://chat.example.com/user"},
+		},
+		Backend: &structures.RepoInfo{
+			MergeBranch:   "dev",
+			TagTemplate:   "v-{branch}-{major}.{minor}.{patch}",
+			MergeStrategy: " squash ",
+		},
+		Repo: &repoconfig.Config{
+			TagType:       "signed",
+			MergeBranches: []string{"dev", "release/*"},
+			Workflow:      "release.yml",
+			Notify:        repoconfig.Notify{Desktop: &off},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		got    any
+		want   any
+		source Source
+		gotSrc Source
+	}{
+		{"merge branch", s.MergeBranch.Value, "dev", SourceBackend, s.MergeBranch.Source},
+		{"merge branches", s.MergeBranches.Value, []string{"dev", "release/*"}, SourceRepo, s.MergeBranches.Source},
+		{"tag template", s.TagTemplate.Value, "v-{branch}-{major}.{minor}.{patch}", SourceBackend, s.TagTemplate.Source},
+		{"tag type", s.TagType.Value, "signed", SourceRepo, s.TagType.Source},
+		{"strategy", s.Strategy.Value, "squash", SourceBackend, s.Strategy.Source},
+		{"workflow", s.Workflow.Value, "release.yml", SourceRepo, s.Workflow.Source},
+		{"desktop", s.NotifyDesktop.Value, false, SourceRepo, s.NotifyDesktop.Source},
+		{"webhooks", s.NotifyWebhooks.Value, []string{"https://chat.example.com/user"}, SourceUser, s.NotifyWebhooks.Source},
+		{"poll interval", s.PollInterval.Value, 10, SourceUser, s.PollInterval.Source},
+		{"poll timeout", s.PollTimeout.Value, 30, SourceDefault, s.PollTimeout.Source},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) || tt.gotSrc != tt.source {
+			t.Errorf("%s = %v from %s, want %v from %s", tt.name, tt.got, tt.gotSrc, tt.want, tt.source)
+		}
+	}
+
+	s.Strategy.Set("rebase", SourceFlag)
+	for _, e := range s.Entries() {
+		if e.Name == "strategy" && (e.Value != "rebase" || e.Source != SourceFlag) {
+			t.Errorf("strategy entry = %+v, want rebase from flag", e)
+		}
+		if e.Name == "merge_branches" && e.Value != "dev, release/*" {
+			t.Errorf("merge_branches entry = %q, want a comma-separated list", e.Value)
+		}
+	}
+}
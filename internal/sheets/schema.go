@@ -0,0 +1,236 @@
+package sheets
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column keys. Headers are matched to keys through an alias table, so the columns
+// of a tab may appear in any order and under any of the listed names.
+const (
+	colRepo         = "repo"
+	colBranch       = "branch"
+	colTime         = "time"
+	colTag          = "tag"
+	colUser         = "user"
+	colOwner        = "owner"
+	colEnvironment  = "environment"
+	colNotes        = "notes"
+	colTagTemplate  = "tag_template"
+	colStrategy     = "merge_strategy"
+	colMergeBranch  = "merge_branch"
+	colCommit       = "commit"
+	colSourceBranch = "source_branch"
+	colOutcome      = "outcome"
+	colExpires      = "expires"
+)
+
+// RepoHeaders are the headers written to an empty merge branches tab, in column order.
+var RepoHeaders = []string{"Repo", "Branch", "Time", "Tag", "User"}
+
+// HistoryHeaders are the headers written to an empty history tab, in column order.
+var HistoryHeaders = []string{"Repo", "Merge Branch", "Tag", "Commit", "Source Branch", "User", "Time", "Outcome"}
+
+// LockHeaders are the headers written to an empty locks tab, in column order.
+var LockHeaders = []string{"Repo", "Owner", "Expires"}
+
+var repoAliases = map[string]string{
+	"repo":          colRepo,
+	"repository":    colRepo,
+	"reponame":      colRepo,
+	"branch":        colBranch,
+	"mergebranch":   colBranch,
+	"time":          colTime,
+	"timestamp":     colTime,
+	"updated":       colTime,
+	"updatedat":     colTime,
+	"tag":           colTag,
+	"latesttag":     colTag,
+	"user":          colUser,
+	"lastuser":      colUser,
+	"updatedby":     colUser,
+	"owner":         colOwner,
+	"environment":   colEnvironment,
+	"env":           colEnvironment,
+	"notes":         colNotes,
+	"note":          colNotes,
+	"tagtemplate":   colTagTemplate,
+	"tagformat":     colTagTemplate,
+	"mergestrategy": colStrategy,
+	"strategy":      colStrategy,
+}
+
+var historyAliases = map[string]string{
+	"repo":         colRepo,
+	"repository":   colRepo,
+	"mergebranch":  colMergeBranch,
+	"tag":          colTag,
+	"commit":       colCommit,
+	"commitsha":    colCommit,
+	"sha":          colCommit,
+	"sourcebranch": colSourceBranch,
+	"user":         colUser,
+	"time":         colTime,
+	"timestamp":    colTime,
+	"outcome":      colOutcome,
+}
+
+var lockAliases = map[string]string{
+	"repo":      colRepo,
+	"owner":     colOwner,
+	"expires":   colExpires,
+	"expiresat": colExpires,
+}
+
+var (
+	repoRequired    = []string{colRepo, colBranch, colTag}
+	historyRequired = []string{colRepo, colTag}
+	lockRequired    = []string{colRepo, colOwner, colExpires}
+)
+
+// errNoHeaderRow reports a tab whose row 1 has none of the known headers.
+var errNoHeaderRow = errors.New("row 1 has no known headers")
+
+// schema maps column keys to zero-based column indices, as read from a header row.
+type schema struct {
+	index map[string]int
+	// firstRow is the zero-based index of the first data row: 1, below the
+	// header row, or 0 in a legacy tab without one.
+	firstRow int
+}
+
+// parseSchema resolves the header row of a tab. Unknown headers are ignored so
+// teams can keep their own columns next to forklift's.
+func parseSchema(tab string, header []interface{}, aliases map[string]string, required []string) (*schema, error) {
+	sc := &schema{index: make(map[string]int), firstRow: 1}
+	for i := range header {
+		text, err := cellText(header, i)
+		if err != nil {
+			return nil, fmt.Errorf("%s: header %s1: %w", tab, columnLetter(i), err)
+		}
+		key, ok := aliases[normalizeHeader(text)]
+		if !ok {
+			continue
+		}
+		if prev, dup := sc.index[key]; dup {
+			return nil, fmt.Errorf("%s: columns %s and %s both map to %q", tab, columnLetter(prev), columnLetter(i), key)
+		}
+		sc.index[key] = i
+	}
+
+	if len(sc.index) == 0 {
+		return nil, fmt.Errorf("%s: %w; run 'forklift sheet setup' to add the header row", tab, errNoHeaderRow)
+	}
+
+	var missing []string
+	for _, key := range required {
+		if !sc.has(key) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s: header row is missing required columns: %s (row 1 must be a header row)", tab, strings.Join(missing, ", "))
+	}
+	return sc, nil
+}
+
+// defaultSchema returns the schema of a tab whose header row is headers.
+func defaultSchema(headers []string, aliases map[string]string) *schema {
+	sc := &schema{index: make(map[string]int), firstRow: 1}
+	for i, h := range headers {
+		sc.index[aliases[normalizeHeader(h)]] = i
+	}
+	return sc
+}
+
+// legacyRepoSchema returns the schema of a repos tab from before header rows
+// were introduced: data from row 1 in the order of RepoHeaders.
+func legacyRepoSchema() *schema {
+	sc := defaultSchema(RepoHeaders, repoAliases)
+	sc.firstRow = 0
+	return sc
+}
+
+func (sc *schema) has(key string) bool {
+	_, ok := sc.index[key]
+	return ok
+}
+
+// column returns the A1 column letter of key.
+func (sc *schema) column(key string) string {
+	return columnLetter(sc.index[key])
+}
+
+// get returns the text of the key column in row. rowIdx is zero-based and only used in errors.
+func (sc *schema) get(row []interface{}, key string, rowIdx int) (string, error) {
+	i, ok := sc.index[key]
+	if !ok {
+		return "", nil
+	}
+	text, err := cellText(row, i)
+	if err != nil {
+		return "", fmt.Errorf("malformed row %d, cell %s%d: %w", rowIdx+1, columnLetter(i), rowIdx+1, err)
+	}
+	return text, nil
+}
+
+// row lays values out in column order. Keys missing from the schema are dropped.
+func (sc *schema) row(values map[string]interface{}) []interface{} {
+	width := 0
+	for key := range values {
+		if i, ok := sc.index[key]; ok && i+1 > width {
+			width = i + 1
+		}
+	}
+	row := make([]interface{}, width)
+	for i := range row {
+		row[i] = ""
+	}
+	for key, v := range values {
+		if i, ok := sc.index[key]; ok {
+			row[i] = v
+		}
+	}
+	return row
+}
+
+// cellText returns the trimmed text of row[i], or "" if the row is too short.
+// Numbers and booleans are formatted; any other value is an error.
+func cellText(row []interface{}, i int) (string, error) {
+	if i >= len(row) || row[i] == nil {
+		return "", nil
+	}
+	switch v := row[i].(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unexpected value of type %T", v)
+	}
+}
+
+func normalizeHeader(h string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(h)))
+}
+
+// columnLetter converts a zero-based column index to its A1 letter (0 -> A, 26 -> AA).
+func columnLetter(i int) string {
+	letters := ""
+	for i >= 0 {
+		letters = string(rune('A'+i%26)) + letters
+		i = i/26 - 1
+	}
+	return letters
+}
+
+// a1 returns an A1 range on the given tab, quoting the tab name.
+func a1(tab, cells string) string {
+	return fmt.Sprintf("'%s'!%s", strings.ReplaceAll(tab, "'", "''"), cells)
+}
@@ -0,0 +1,401 @@
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"forklift/internal/git"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// MetaHeaders are the headers of the meta tab, which records applied migrations.
+var MetaHeaders = []string{"Version", "Description", "Applied At", "Applied By"}
+
+// migration moves the spreadsheet layout forward by one version.
+type migration struct {
+	version     int
+	description string
+	apply       func(ctx context.Context, s *Service, tabs map[string]*sheets.SheetProperties) error
+}
+
+// migrations are applied in order by Setup. Append new ones; never edit or
+// reorder released ones, their versions are recorded in spreadsheets.
+var migrations = []migration{
+	{1, "Add header rows, inserting one above legacy headerless data", migrateHeaders},
+	{2, "Add optional Owner, Environment and Notes columns", migrateOptionalColumns},
+	{3, "Add optional Tag Template column", migrateTagTemplateColumn},
+	{4, "Add optional Merge Strategy column", migrateStrategyColumn},
+}
+
+// Setup creates the tabs forklift needs, migrates their layout to the latest
+// version and (re)applies frozen header rows and data validation.
+// It is safe to run repeatedly. It returns a description of each change made.
+func (s *Service) Setup(ctx context.Context) ([]string, error) {
+	var report []string
+
+	tabs, err := s.tabProperties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Create missing tabs
+	for _, title := range []string{s.tabs.Repos, s.tabs.History, s.tabs.Locks, s.tabs.Meta} {
+		if _, ok := tabs[title]; ok {
+			continue
+		}
+		resp, err := s.batchUpdate(ctx, &sheets.Request{
+			AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: title}},
+		})
+		if err != nil {
+			return report, fmt.Errorf("failed to create tab %s: %w", title, err)
+		}
+		tabs[title] = resp.Replies[0].AddSheet.Properties
+		report = append(report, fmt.Sprintf("Created tab %s", title))
+	}
+
+	// 2. Migrate
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := m.apply(ctx, s, tabs); err != nil {
+			return report, fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+		if err := s.recordMigration(ctx, m); err != nil {
+			return report, err
+		}
+		report = append(report, fmt.Sprintf("Applied migration %d: %s", m.version, m.description))
+	}
+
+	// 3. Formatting
+	var requests []*sheets.Request
+	for _, title := range []string{s.tabs.Repos, s.tabs.History, s.tabs.Locks, s.tabs.Meta} {
+		requests = append(requests, &sheets.Request{
+			UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
+				Properties: &sheets.SheetProperties{
+					SheetId:         tabs[title].SheetId,
+					GridProperties:  &sheets.GridProperties{FrozenRowCount: 1},
+					ForceSendFields: []string{"SheetId"},
+				},
+				Fields: "gridProperties.frozenRowCount",
+			},
+		})
+	}
+	validations, err := s.validationRequests(ctx, tabs)
+	if err != nil {
+		return report, err
+	}
+	requests = append(requests, validations...)
+	if _, err := s.batchUpdate(ctx, requests...); err != nil {
+		return report, fmt.Errorf("failed to format tabs: %w", err)
+	}
+	report = append(report, "Froze header rows and applied data validation")
+
+	return report, nil
+}
+
+// schemaVersion returns the layout version recorded in the meta tab, 0 if none.
+func (s *Service) schemaVersion(ctx context.Context) (int, error) {
+	resp, err := s.srv.Spreadsheets.Values.Get(s.sheetID, a1(s.tabs.Meta, "A:A")).Context(ctx).Do()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for i, row := range resp.Values {
+		if i == 0 {
+			continue // header
+		}
+		text, err := cellText(row, 0)
+		if err != nil || text == "" {
+			continue
+		}
+		v, err := strconv.Atoi(text)
+		if err != nil {
+			return 0, fmt.Errorf("%s: malformed version %q in row %d", s.tabs.Meta, text, i+1)
+		}
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+func (s *Service) recordMigration(ctx context.Context, m migration) error {
+	if err := s.ensureHeaders(ctx, s.tabs.Meta, MetaHeaders); err != nil {
+		return err
+	}
+	values := []interface{}{
+		m.version,
+		m.description,
+		time.Now().UTC().Format(time.RFC3339),
+		git.UserIdentity(),
+	}
+	vr := &sheets.ValueRange{Values: [][]interface{}{values}}
+	_, err := s.srv.Spreadsheets.Values.Append(s.sheetID, a1(s.tabs.Meta, "A1"), vr).
+		ValueInputOption("RAW").
+		InsertDataOption("INSERT_ROWS").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+	return nil
+}
+
+// migrateHeaders gives every tab a header row. Before headers were introduced
+// the repos tab held data from row 1 in the order Repo, Branch, Time, Tag, User,
+// so a repo name in A1 means a header row has to be inserted above it.
+func migrateHeaders(ctx context.Context, s *Service, tabs map[string]*sheets.SheetProperties) error {
+	header, err := s.headerRow(ctx, s.tabs.Repos)
+	if err != nil {
+		return err
+	}
+	first, _ := cellText(header, 0)
+	if len(header) > 0 && strings.Contains(first, "/") {
+		_, err := s.batchUpdate(ctx, &sheets.Request{
+			InsertDimension: &sheets.InsertDimensionRequest{
+				Range: &sheets.DimensionRange{
+					SheetId:         tabs[s.tabs.Repos].SheetId,
+					Dimension:       "ROWS",
+					StartIndex:      0,
+					EndIndex:        1,
+					ForceSendFields: []string{"SheetId", "StartIndex"},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to insert header row into %s: %w", s.tabs.Repos, err)
+		}
+	}
+
+	for tab, headers := range map[string][]string{
+		s.tabs.Repos:   RepoHeaders,
+		s.tabs.History: HistoryHeaders,
+		s.tabs.Locks:   LockHeaders,
+		s.tabs.Meta:    MetaHeaders,
+	} {
+		if err := s.ensureHeaders(ctx, tab, headers); err != nil {
+			return err
+		}
+	}
+
+	// Fail early on header rows forklift cannot read
+	checks := []struct {
+		tab      string
+		aliases  map[string]string
+		required []string
+	}{
+		{s.tabs.Repos, repoAliases, repoRequired},
+		{s.tabs.History, historyAliases, historyRequired},
+		{s.tabs.Locks, lockAliases, lockRequired},
+	}
+	for _, c := range checks {
+		header, err := s.headerRow(ctx, c.tab)
+		if err != nil {
+			return err
+		}
+		if _, err := parseSchema(c.tab, header, c.aliases, c.required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repoColumn is an optional column of the repos tab.
+type repoColumn struct{ key, title string }
+
+// migrateOptionalColumns appends the optional repo columns that are missing.
+func migrateOptionalColumns(ctx context.Context, s *Service, tabs map[string]*sheets.SheetProperties) error {
+	return s.appendRepoColumns(ctx, []repoColumn{
+		{colOwner, "Owner"},
+		{colEnvironment, "Environment"},
+		{colNotes, "Notes"},
+	})
+}
+
+// migrateTagTemplateColumn appends the per-repo tag template column.
+func migrateTagTemplateColumn(ctx context.Context, s *Service, tabs map[string]*sheets.SheetProperties) error {
+	return s.appendRepoColumns(ctx, []repoColumn{{colTagTemplate, "Tag Template"}})
+}
+
+// migrateStrategyColumn appends the per-repo merge strategy column.
+func migrateStrategyColumn(ctx context.Context, s *Service, tabs map[string]*sheets.SheetProperties) error {
+	return s.appendRepoColumns(ctx, []repoColumn{{colStrategy, "Merge Strategy"}})
+}
+
+// appendRepoColumns appends the given columns to the repos tab, skipping the
+// ones it already has.
+func (s *Service) appendRepoColumns(ctx context.Context, columns []repoColumn) error {
+	header, err := s.headerRow(ctx, s.tabs.Repos)
+	if err != nil {
+		return err
+	}
+	sc, err := parseSchema(s.tabs.Repos, header, repoAliases, repoRequired)
+	if err != nil {
+		return err
+	}
+
+	var missing []interface{}
+	for _, h := range columns {
+		if !sc.has(h.key) {
+			missing = append(missing, h.title)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	cell := fmt.Sprintf("%s1", columnLetter(len(header)))
+	vr := &sheets.ValueRange{Values: [][]interface{}{missing}}
+	_, err = s.srv.Spreadsheets.Values.Update(s.sheetID, a1(s.tabs.Repos, cell), vr).
+		ValueInputOption("RAW").
+		Context(ctx).
+		Do()
+	return err
+}
+
+// validationRequests builds the data validation rules for the current layout.
+func (s *Service) validationRequests(ctx context.Context, tabs map[string]*sheets.SheetProperties) ([]*sheets.Request, error) {
+	repoHeader, err := s.headerRow(ctx, s.tabs.Repos)
+	if err != nil {
+		return nil, err
+	}
+	repoSchema, err := parseSchema(s.tabs.Repos, repoHeader, repoAliases, repoRequired)
+	if err != nil {
+		return nil, err
+	}
+	historyHeader, err := s.headerRow(ctx, s.tabs.History)
+	if err != nil {
+		return nil, err
+	}
+	historySchema, err := parseSchema(s.tabs.History, historyHeader, historyAliases, historyRequired)
+	if err != nil {
+		return nil, err
+	}
+
+	// Repo names must look like org/repo; only warn, forklift itself tolerates anything
+	repoCol := repoSchema.index[colRepo]
+	requests := []*sheets.Request{
+		validation(tabs[s.tabs.Repos].SheetId, repoCol, &sheets.DataValidationRule{
+			Condition: &sheets.BooleanCondition{
+				Type: "CUSTOM_FORMULA",
+				Values: []*sheets.ConditionValue{
+					{UserEnteredValue: fmt.Sprintf(`=REGEXMATCH(%s2, "^[^/]+/[^/]+$")`, columnLetter(repoCol))},
+				},
+			},
+			InputMessage: "Repository as org/repo",
+		}),
+	}
+
+	if repoSchema.has(colStrategy) {
+		requests = append(requests, validation(tabs[s.tabs.Repos].SheetId, repoSchema.index[colStrategy], &sheets.DataValidationRule{
+			Condition: &sheets.BooleanCondition{
+				Type: "ONE_OF_LIST",
+				Values: []*sheets.ConditionValue{
+					{UserEnteredValue: "merge"},
+					{UserEnteredValue: "squash"},
+					{UserEnteredValue: "rebase"},
+					{UserEnteredValue: "ff-only"},
+				},
+			},
+			Strict:       true,
+			ShowCustomUi: true,
+		}))
+	}
+	if historySchema.has(colOutcome) {
+		requests = append(requests, validation(tabs[s.tabs.History].SheetId, historySchema.index[colOutcome], &sheets.DataValidationRule{
+			Condition: &sheets.BooleanCondition{
+				Type: "ONE_OF_LIST",
+				Values: []*sheets.ConditionValue{
+					{UserEnteredValue: "success"},
+					{UserEnteredValue: "failed"},
+					{UserEnteredValue: "conflict"},
+				},
+			},
+			Strict:       true,
+			ShowCustomUi: true,
+		}))
+	}
+	return requests, nil
+}
+
+// validation applies rule to every data row of one column.
+func validation(sheetID int64, col int, rule *sheets.DataValidationRule) *sheets.Request {
+	return &sheets.Request{
+		SetDataValidation: &sheets.SetDataValidationRequest{
+			Range: &sheets.GridRange{
+				SheetId:          sheetID,
+				StartRowIndex:    1,
+				StartColumnIndex: int64(col),
+				EndColumnIndex:   int64(col) + 1,
+				ForceSendFields:  []string{"SheetId", "StartColumnIndex"},
+			},
+			Rule: rule,
+		},
+	}
+}
+
+// tabProperties returns the properties of every tab, keyed by title.
+func (s *Service) tabProperties(ctx context.Context) (map[string]*sheets.SheetProperties, error) {
+	resp, err := s.srv.Spreadsheets.Get(s.sheetID).Fields("sheets.properties").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spreadsheet: %w", err)
+	}
+	tabs := make(map[string]*sheets.SheetProperties)
+	for _, sh := range resp.Sheets {
+		if sh.Properties != nil {
+			tabs[sh.Properties.Title] = sh.Properties
+		}
+	}
+	return tabs, nil
+}
+
+func (s *Service) headerRow(ctx context.Context, tab string) ([]interface{}, error) {
+	resp, err := s.srv.Spreadsheets.Values.Get(s.sheetID, a1(tab, "1:1")).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Values) == 0 {
+		return nil, nil
+	}
+	return resp.Values[0], nil
+}
+
+// ensureHeaders writes headers to row 1 of tab if that row is empty.
+func (s *Service) ensureHeaders(ctx context.Context, tab string, headers []string) error {
+	header, err := s.headerRow(ctx, tab)
+	if err != nil {
+		return err
+	}
+	if len(header) > 0 {
+		return nil
+	}
+	return s.writeHeaders(ctx, tab, headers)
+}
+
+func (s *Service) writeHeaders(ctx context.Context, tab string, headers []string) error {
+	row := make([]interface{}, len(headers))
+	for i, h := range headers {
+		row[i] = h
+	}
+	vr := &sheets.ValueRange{Values: [][]interface{}{row}}
+	_, err := s.srv.Spreadsheets.Values.Update(s.sheetID, a1(tab, "A1"), vr).
+		ValueInputOption("RAW").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return fmt.Errorf("failed to write headers to %s: %w", tab, err)
+	}
+	return nil
+}
+
+func (s *Service) batchUpdate(ctx context.Context, requests ...*sheets.Request) (*sheets.BatchUpdateSpreadsheetResponse, error) {
+	req := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	return s.srv.Spreadsheets.BatchUpdate(s.sheetID, req).Context(ctx).Do()
+}
@@ -0,0 +1,57 @@
+package sheets
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSetupMigratesLegacyLayout(t *testing.T) {
+	s, srv := newTestService(t, [][]interface{}{
+		{"org/repo", "dev", "2025-01-01T00:00:00Z", "v-dev-0.0.4", "alice"},
+	})
+	ctx := context.Background()
+
+	report, err := s.Setup(ctx)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if len(report) == 0 {
+		t.Fatal("Setup() reported no changes")
+	}
+
+	wantRepos := [][]interface{}{
+		{"Repo", "Branch", "Time", "Tag", "User", "Owner", "Environment", "Notes", "Tag Template", "Merge Strategy"},
+		{"org/repo", "dev", "2025-01-01T00:00:00Z", "v-dev-0.0.4", "alice"},
+	}
+	if got := srv.Values(testSheetID, testTabs.Repos); !reflect.DeepEqual(got, wantRepos) {
+		t.Errorf("repos tab = %v, want %v", got, wantRepos)
+	}
+	for _, tab := range []string{testTabs.History, testTabs.Locks, testTabs.Meta} {
+		if rows := srv.Values(testSheetID, tab); len(rows) == 0 {
+			t.Errorf("tab %s has no header row", tab)
+		}
+	}
+
+	info, err := s.GetRepoInfo(ctx, "org/repo")
+	if err != nil || info == nil || info.LatestTag != "v-dev-0.0.4" {
+		t.Fatalf("GetRepoInfo() after setup = %+v, %v", info, err)
+	}
+
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("schemaVersion() error = %v", err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+
+	// A second run only reapplies formatting
+	report, err = s.Setup(ctx)
+	if err != nil {
+		t.Fatalf("second Setup() error = %v", err)
+	}
+	if len(report) != 1 {
+		t.Errorf("second Setup() report = %v, want formatting only", report)
+	}
+}
@@ -16,100 +16,414 @@ import (
 	"google.golang.org/api/sheets/v4"
 )
 
+// Service reads and writes repository information and tag history in tabs of a Google Sheet.
+// Columns are located through the header row of each tab, see schema.go.
 type Service struct {
-	srv *sheets.Service
+	srv     *sheets.Service
+	sheetID string
+	tabs    Tabs
+
+	lockSettleDelay time.Duration
+}
+
+// Tabs names the tabs of the spreadsheet forklift uses.
+type Tabs struct {
+	Repos   string // merge branch and latest tag per repository
+	History string // one row per build
+	Locks   string // build leases
+	Meta    string // applied layout migrations
 }
 
-func NewService(ctx context.Context, credentialsPath string) (*Service, error) {
-	if !filepath.IsAbs(credentialsPath) {
-		return nil, fmt.Errorf("FORKLIFT_GOOGLE_CREDENTIALS must be an absolute path: %s", credentialsPath)
+// NewService creates a Sheets client authenticated with the service account in
+// credentialsPath. Extra options, such as option.WithEndpoint, are applied last.
+// An empty credentialsPath disables authentication, which is only useful together
+// with a custom endpoint such as a local fake.
+func NewService(ctx context.Context, credentialsPath, sheetID string, tabs Tabs, opts ...option.ClientOption) (*Service, error) {
+	clientOpts := []option.ClientOption{option.WithScopes(sheets.SpreadsheetsScope)}
+	if credentialsPath == "" {
+		clientOpts = append(clientOpts, option.WithoutAuthentication())
+	} else {
+		if !filepath.IsAbs(credentialsPath) {
+			return nil, fmt.Errorf("FORKLIFT_GOOGLE_CREDENTIALS must be an absolute path: %s", credentialsPath)
+		}
+		clientOpts = append(clientOpts, option.WithCredentialsFile(credentialsPath))
 	}
+	clientOpts = append(clientOpts, opts...)
 
-	srv, err := sheets.NewService(ctx, option.WithCredentialsFile(credentialsPath), option.WithScopes(sheets.SpreadsheetsScope))
+	srv, err := sheets.NewService(ctx, clientOpts...)
 	if err != nil {
 		return nil, err
 	}
-	return &Service{srv: srv}, nil
+	return &Service{srv: srv, sheetID: sheetID, tabs: tabs, lockSettleDelay: lockSettleDelay}, nil
 }
 
 // GetRepoInfo returns a structures.RepoInfo struct for the given repository.
 // If the repository is not found, it returns nil and no error.
-func (s *Service) GetRepoInfo(ctx context.Context, sheetID, sheetName, repo string) (*structures.RepoInfo, error) {
-	rangeName := fmt.Sprintf("%s!A:E", sheetName)
-	resp, err := s.srv.Spreadsheets.Values.Get(sheetID, rangeName).Context(ctx).Do()
+func (s *Service) GetRepoInfo(ctx context.Context, repo string) (*structures.RepoInfo, error) {
+	sc, rows, err := s.readTab(ctx, s.tabs.Repos, repoAliases, repoRequired)
 	if err != nil {
 		return nil, err
 	}
+	if sc == nil {
+		return nil, nil
+	}
 
-	for i, row := range resp.Values {
-		if len(row) < 1 {
-			continue
+	var info *structures.RepoInfo
+	for i, row := range rows {
+		if i < sc.firstRow {
+			continue // header
+		}
+		repoName, err := sc.get(row, colRepo, i)
+		if err != nil {
+			return nil, err
 		}
-		repoName, ok := row[0].(string)
-		if !ok {
+		if repoName != repo {
 			continue
 		}
-		if repoName == repo {
-			info := &structures.RepoInfo{
-				RowIdx: i,
-			}
-			if len(row) > 1 {
-				info.MergeBranch = strings.TrimSpace(row[1].(string))
-			}
-			// Column D is index 3 (Repo, Branch, Time, Tag)
-			if len(row) > 3 {
-				info.LatestTag = strings.TrimSpace(row[3].(string))
-			}
-			// Column E is index 4 (User)
-			if len(row) > 4 {
-				info.LastUser = strings.TrimSpace(row[4].(string))
+		if info != nil {
+			return nil, fmt.Errorf("%s: repo %s appears in both row %d and row %d", s.tabs.Repos, repo, info.RowIdx+1, i+1)
+		}
+
+		info = &structures.RepoInfo{RowIdx: i}
+		fields := map[string]*string{
+			colBranch:      &info.MergeBranch,
+			colTag:         &info.LatestTag,
+			colUser:        &info.LastUser,
+			colOwner:       &info.Owner,
+			colEnvironment: &info.Environment,
+			colNotes:       &info.Notes,
+			colTagTemplate: &info.TagTemplate,
+			colStrategy:    &info.MergeStrategy,
+		}
+		for key, dst := range fields {
+			if *dst, err = sc.get(row, key, i); err != nil {
+				return nil, err
 			}
-			return info, nil
 		}
 	}
 
-	return nil, nil
+	return info, nil
 }
 
-func (s *Service) SetMergeBranch(ctx context.Context, sheetID, sheetName, repo, branch string, rowIdx int) error {
+func (s *Service) SetMergeBranch(ctx context.Context, repo, branch string, rowIdx int) error {
 	timestamp := time.Now().UTC().Format(time.RFC3339)
 	user := git.UserIdentity()
 
-	if rowIdx >= 0 {
-		// Update existing row (clearing tag for new sequence, updating user)
-		values := []interface{}{branch, timestamp, "", user}
-		vr := &sheets.ValueRange{Values: [][]interface{}{values}}
-		rangeName := fmt.Sprintf("%s!B%d:E%d", sheetName, rowIdx+1, rowIdx+1) // Sheet is 1-indexed
-		_, err := s.srv.Spreadsheets.Values.Update(sheetID, rangeName, vr).
-			ValueInputOption("RAW").
-			Context(ctx).
-			Do()
+	sc, err := s.readSchema(ctx, s.tabs.Repos, RepoHeaders, repoAliases, repoRequired)
+	if err != nil {
 		return err
 	}
 
-	// Append new row
-	values := []interface{}{repo, branch, timestamp, "", user}
-	vr := &sheets.ValueRange{Values: [][]interface{}{values}}
-	_, err := s.srv.Spreadsheets.Values.Append(sheetID, fmt.Sprintf("%s!A:E", sheetName), vr).
-		ValueInputOption("RAW").
-		InsertDataOption("INSERT_ROWS").
-		Context(ctx).
-		Do()
-	return err
+	// Clearing tag for new sequence, updating user
+	values := map[string]interface{}{
+		colBranch: branch,
+		colTime:   timestamp,
+		colTag:    "",
+		colUser:   user,
+	}
+
+	if rowIdx >= 0 {
+		return s.updateCells(ctx, s.tabs.Repos, sc, rowIdx, values)
+	}
+
+	values[colRepo] = repo
+	return s.appendRow(ctx, s.tabs.Repos, sc, values)
 }
 
-func (s *Service) UpdateRepoTag(ctx context.Context, sheetID, sheetName string, rowIdx int, tag string) error {
+// UpdateRepoTag re-reads the row before writing and refuses to overwrite a tag other
+// than expectedTag. The Sheets API has no compare-and-swap, so this narrows the race
+// rather than closing it; builds also hold the lock from AcquireLock.
+func (s *Service) UpdateRepoTag(ctx context.Context, rowIdx int, expectedTag, tag string) error {
 	if rowIdx < 0 {
 		return errors.New("cannot update tag for non-existent repo row")
 	}
-	// Update Tag (Col D), Time (Col C), and User (Col E)
-	timestamp := time.Now().UTC().Format(time.RFC3339)
-	user := git.UserIdentity()
-	values := []interface{}{timestamp, tag, user}
-	vr := &sheets.ValueRange{Values: [][]interface{}{values}}
-	rangeName := fmt.Sprintf("%s!C%d:E%d", sheetName, rowIdx+1, rowIdx+1)
-	_, err := s.srv.Spreadsheets.Values.Update(sheetID, rangeName, vr).
+	sc, rows, err := s.readTab(ctx, s.tabs.Repos, repoAliases, repoRequired)
+	if err != nil {
+		return err
+	}
+	if sc == nil || rowIdx < sc.firstRow || rowIdx >= len(rows) {
+		return errors.New("cannot update tag for non-existent repo row")
+	}
+	current, err := sc.get(rows[rowIdx], colTag, rowIdx)
+	if err != nil {
+		return err
+	}
+	if current != expectedTag {
+		return fmt.Errorf("%w: expected %q, found %q", structures.ErrTagConflict, expectedTag, current)
+	}
+	// Update Tag, Time and User
+	return s.updateCells(ctx, s.tabs.Repos, sc, rowIdx, map[string]interface{}{
+		colTime: time.Now().UTC().Format(time.RFC3339),
+		colTag:  tag,
+		colUser: git.UserIdentity(),
+	})
+}
+
+// AppendHistory appends a build to the history tab. One row is written per build,
+// so earlier tags are never overwritten.
+func (s *Service) AppendHistory(ctx context.Context, record structures.HistoryRecord) error {
+	sc, err := s.readSchema(ctx, s.tabs.History, HistoryHeaders, historyAliases, historyRequired)
+	if err != nil {
+		return err
+	}
+	return s.appendRow(ctx, s.tabs.History, sc, map[string]interface{}{
+		colRepo:         record.Repo,
+		colMergeBranch:  record.MergeBranch,
+		colTag:          record.Tag,
+		colCommit:       record.CommitSHA,
+		colSourceBranch: record.SourceBranch,
+		colUser:         record.User,
+		colTime:         record.Timestamp,
+		colOutcome:      record.Outcome,
+	})
+}
+
+// ListHistory returns the history rows of the given repository, oldest first.
+func (s *Service) ListHistory(ctx context.Context, repo string) ([]structures.HistoryRecord, error) {
+	sc, rows, err := s.readTab(ctx, s.tabs.History, historyAliases, historyRequired)
+	if err != nil {
+		return nil, err
+	}
+	if sc == nil {
+		return nil, nil
+	}
+
+	var records []structures.HistoryRecord
+	for i, row := range rows {
+		if i < sc.firstRow {
+			continue // header
+		}
+		repoName, err := sc.get(row, colRepo, i)
+		if err != nil {
+			return nil, err
+		}
+		if repoName != repo {
+			continue
+		}
+
+		record := structures.HistoryRecord{Repo: repo}
+		fields := map[string]*string{
+			colMergeBranch:  &record.MergeBranch,
+			colTag:          &record.Tag,
+			colCommit:       &record.CommitSHA,
+			colSourceBranch: &record.SourceBranch,
+			colUser:         &record.User,
+			colTime:         &record.Timestamp,
+			colOutcome:      &record.Outcome,
+		}
+		for key, dst := range fields {
+			if *dst, err = sc.get(row, key, i); err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// lockSettleDelay is how long AcquireLock waits before reading its lock back,
+// so a concurrent writer's update has landed and the loser can tell.
+const lockSettleDelay = time.Second
+
+func (s *Service) AcquireLock(ctx context.Context, repo, owner string, ttl time.Duration, steal bool) (*structures.Lock, error) {
+	sc, rowIdx, current, err := s.findLock(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if current != nil && current.Owner != "" && current.Owner != owner && current.Expires.After(now) && !steal {
+		return nil, fmt.Errorf("%w: %s holds %s until %s", structures.ErrLockHeld, current.Owner, repo, current.Expires.Local().Format(time.Kitchen))
+	}
+
+	lock := &structures.Lock{Repo: repo, Owner: owner, Expires: now.Add(ttl)}
+	values := map[string]interface{}{
+		colOwner:   lock.Owner,
+		colExpires: lock.Expires.Format(time.RFC3339),
+	}
+	if rowIdx >= 0 {
+		err = s.updateCells(ctx, s.tabs.Locks, sc, rowIdx, values)
+	} else {
+		values[colRepo] = repo
+		err = s.appendRow(ctx, s.tabs.Locks, sc, values)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Read back to find out whether we won. Concurrent writers to the same row
+	// leave the last one in it; concurrent appends for a repo without a row each
+	// add one, and the lowest row wins.
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(s.lockSettleDelay):
+	}
+	sc, locks, err := s.findLocks(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(locks) > 0 && locks[0].lock.Owner == owner {
+		return lock, nil
+	}
+	// Clear the rows we appended so they don't pile up
+	for _, l := range locks {
+		if l.lock.Owner != owner {
+			continue
+		}
+		if err := s.updateCells(ctx, s.tabs.Locks, sc, l.idx, map[string]interface{}{
+			colOwner:   "",
+			colExpires: "",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	holder := "another build"
+	if len(locks) > 0 && locks[0].lock.Owner != "" {
+		holder = locks[0].lock.Owner
+	}
+	return nil, fmt.Errorf("%w: %s took %s at the same time", structures.ErrLockHeld, holder, repo)
+}
+
+func (s *Service) ReleaseLock(ctx context.Context, repo, owner string) error {
+	sc, rowIdx, current, err := s.findLock(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Owner != owner {
+		return nil
+	}
+	return s.updateCells(ctx, s.tabs.Locks, sc, rowIdx, map[string]interface{}{
+		colOwner:   "",
+		colExpires: "",
+	})
+}
+
+// findLock returns the schema of the locks tab and the row index and lock of repo.
+// If repo has several rows, the first one holds the lock. If repo has no row,
+// the index is -1 and the lock nil.
+func (s *Service) findLock(ctx context.Context, repo string) (*schema, int, *structures.Lock, error) {
+	sc, locks, err := s.findLocks(ctx, repo)
+	if err != nil || len(locks) == 0 {
+		return sc, -1, nil, err
+	}
+	return sc, locks[0].idx, locks[0].lock, nil
+}
+
+// lockRow is a row of the locks tab.
+type lockRow struct {
+	idx  int
+	lock *structures.Lock
+}
+
+// findLocks returns the schema of the locks tab and all rows of repo in sheet order.
+func (s *Service) findLocks(ctx context.Context, repo string) (*schema, []lockRow, error) {
+	sc, rows, err := s.readTab(ctx, s.tabs.Locks, lockAliases, lockRequired)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sc == nil {
+		sc, err = s.readSchema(ctx, s.tabs.Locks, LockHeaders, lockAliases, lockRequired)
+		return sc, nil, err
+	}
+
+	var locks []lockRow
+	for i, row := range rows {
+		if i < sc.firstRow {
+			continue // header
+		}
+		repoName, err := sc.get(row, colRepo, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		if repoName != repo {
+			continue
+		}
+		lock := &structures.Lock{Repo: repo}
+		if lock.Owner, err = sc.get(row, colOwner, i); err != nil {
+			return nil, nil, err
+		}
+		expires, err := sc.get(row, colExpires, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		if expires != "" {
+			if lock.Expires, err = time.Parse(time.RFC3339, expires); err != nil {
+				return nil, nil, fmt.Errorf("malformed row %d of %s: invalid expiry %q", i+1, s.tabs.Locks, expires)
+			}
+		}
+		locks = append(locks, lockRow{idx: i, lock: lock})
+	}
+	return sc, locks, nil
+}
+
+// readTab returns the schema and all rows of a tab, header included.
+// An empty tab yields a nil schema and no error.
+func (s *Service) readTab(ctx context.Context, tab string, aliases map[string]string, required []string) (*schema, [][]interface{}, error) {
+	resp, err := s.srv.Spreadsheets.Values.Get(s.sheetID, a1(tab, "A:ZZ")).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resp.Values) == 0 {
+		return nil, nil, nil
+	}
+	sc, err := s.parseSchema(tab, resp.Values[0], aliases, required)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sc, resp.Values, nil
+}
+
+// parseSchema resolves the header row of a tab like the package-level
+// parseSchema, but reads a repos tab without header row in the legacy
+// layout, so sheets that were never migrated keep working.
+func (s *Service) parseSchema(tab string, header []interface{}, aliases map[string]string, required []string) (*schema, error) {
+	sc, err := parseSchema(tab, header, aliases, required)
+	if errors.Is(err, errNoHeaderRow) && tab == s.tabs.Repos {
+		return legacyRepoSchema(), nil
+	}
+	return sc, err
+}
+
+// readSchema reads the header row of a tab. If the tab is empty, the default
+// headers are written first so the tab documents itself.
+func (s *Service) readSchema(ctx context.Context, tab string, defaults []string, aliases map[string]string, required []string) (*schema, error) {
+	header, err := s.headerRow(ctx, tab)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		return s.parseSchema(tab, header, aliases, required)
+	}
+	if err := s.writeHeaders(ctx, tab, defaults); err != nil {
+		return nil, err
+	}
+	return defaultSchema(defaults, aliases), nil
+}
+
+// updateCells writes values into the row at rowIdx, one cell per column key.
+// Keys missing from the schema are skipped.
+func (s *Service) updateCells(ctx context.Context, tab string, sc *schema, rowIdx int, values map[string]interface{}) error {
+	req := &sheets.BatchUpdateValuesRequest{ValueInputOption: "RAW"}
+	for key, v := range values {
+		if !sc.has(key) {
+			continue
+		}
+		cell := fmt.Sprintf("%s%d", sc.column(key), rowIdx+1) // Sheet is 1-indexed
+		req.Data = append(req.Data, &sheets.ValueRange{
+			Range:  a1(tab, cell),
+			Values: [][]interface{}{{v}},
+		})
+	}
+	_, err := s.srv.Spreadsheets.Values.BatchUpdate(s.sheetID, req).Context(ctx).Do()
+	return err
+}
+
+func (s *Service) appendRow(ctx context.Context, tab string, sc *schema, values map[string]interface{}) error {
+	vr := &sheets.ValueRange{Values: [][]interface{}{sc.row(values)}}
+	_, err := s.srv.Spreadsheets.Values.Append(s.sheetID, a1(tab, "A1"), vr).
 		ValueInputOption("RAW").
+		InsertDataOption("INSERT_ROWS").
 		Context(ctx).
 		Do()
 	return err
@@ -0,0 +1,398 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"forklift/internal/sheets/sheetstest"
+	"forklift/internal/structures"
+
+	"google.golang.org/api/option"
+)
+
+const testSheetID = "test-sheet"
+
+var testTabs = Tabs{
+	Repos:   "merge_branches",
+	History: "merge_history",
+	Locks:   "forklift_locks",
+	Meta:    "forklift_meta",
+}
+
+func newTestService(t *testing.T, repoRows [][]interface{}) (*Service, *sheetstest.Server) {
+	t.Helper()
+	srv := sheetstest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetValues(testSheetID, testTabs.Repos, repoRows)
+
+	s, err := NewService(context.Background(), "", testSheetID, testTabs,
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s, srv
+}
+
+func header() []interface{} {
+	return []interface{}{"Repo", "Branch", "Time", "Tag", "User"}
+}
+
+func TestGetRepoInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [][]interface{}
+		repo    string
+		want    *structures.RepoInfo
+		wantErr string
+	}{
+		{
+			name: "full row",
+			rows: [][]interface{}{
+				header(),
+				{"org/other", "main", "", "v-main-0.0.9", "bob"},
+				{"org/repo", "dev", "2026-01-01T00:00:00Z", "v-dev-0.0.3", "alice"},
+			},
+			repo: "org/repo",
+			want: &structures.RepoInfo{RowIdx: 2, MergeBranch: "dev", LatestTag: "v-dev-0.0.3", LastUser: "alice"},
+		},
+		{
+			name: "missing repo",
+			rows: [][]interface{}{header(), {"org/other", "main"}},
+			repo: "org/repo",
+			want: nil,
+		},
+		{
+			name: "empty tab",
+			rows: nil,
+			repo: "org/repo",
+			want: nil,
+		},
+		{
+			name: "short row",
+			rows: [][]interface{}{header(), {"org/repo", " dev "}},
+			repo: "org/repo",
+			want: &structures.RepoInfo{RowIdx: 1, MergeBranch: "dev"},
+		},
+		{
+			name: "non-string cells",
+			rows: [][]interface{}{header(), {"org/repo", 2026, "", 1.5, true}},
+			repo: "org/repo",
+			want: &structures.RepoInfo{RowIdx: 1, MergeBranch: "2026", LatestTag: "1.5", LastUser: "true"},
+		},
+		{
+			name: "reordered and optional columns",
+			rows: [][]interface{}{
+				{"Latest Tag", "Owner", "Repository", "Notes", "Merge Branch", "Environment"},
+				{"v-qa-1.0.0", "team-a", "org/repo", "frozen", "qa", "staging"},
+			},
+			repo: "org/repo",
+			want: &structures.RepoInfo{
+				RowIdx:      1,
+				MergeBranch: "qa",
+				LatestTag:   "v-qa-1.0.0",
+				Owner:       "team-a",
+				Environment: "staging",
+				Notes:       "frozen",
+			},
+		},
+		{
+			name:    "missing required column",
+			rows:    [][]interface{}{{"Repo", "Time", "Tag"}, {"org/repo", "", "v1"}},
+			repo:    "org/repo",
+			wantErr: "missing required columns: branch",
+		},
+		{
+			name: "headerless legacy layout",
+			rows: [][]interface{}{{"org/other", "main"}, {"org/repo", "dev", "", "v-dev-0.0.1", "alice"}},
+			repo: "org/repo",
+			want: &structures.RepoInfo{RowIdx: 1, MergeBranch: "dev", LatestTag: "v-dev-0.0.1", LastUser: "alice"},
+		},
+		{
+			name: "headerless legacy layout, first row",
+			rows: [][]interface{}{{"org/repo", "dev", "", "v-dev-0.0.1", "alice"}},
+			repo: "org/repo",
+			want: &structures.RepoInfo{RowIdx: 0, MergeBranch: "dev", LatestTag: "v-dev-0.0.1", LastUser: "alice"},
+		},
+		{
+			name:    "duplicate repo",
+			rows:    [][]interface{}{header(), {"org/repo", "dev"}, {"org/repo", "main"}},
+			repo:    "org/repo",
+			wantErr: "appears in both row 2 and row 3",
+		},
+		{
+			name:    "malformed cell",
+			rows:    [][]interface{}{header(), {"org/repo", "dev", "", map[string]interface{}{"x": 1}}},
+			repo:    "org/repo",
+			wantErr: "malformed row 2, cell D2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t, tt.rows)
+			got, err := s.GetRepoInfo(context.Background(), tt.repo)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetRepoInfo() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetRepoInfo() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRepoInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetMergeBranch(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   [][]interface{}
+		rowIdx int
+		want   [][]interface{} // repo, branch and tag columns only
+	}{
+		{
+			name:   "append new repo",
+			rows:   [][]interface{}{header(), {"org/other", "main", "", "v-main-0.0.1", "bob"}},
+			rowIdx: -1,
+			want:   [][]interface{}{{"Repo", "Branch", "Tag"}, {"org/other", "main", "v-main-0.0.1"}, {"org/repo", "prod", ""}},
+		},
+		{
+			name:   "existing row starts a new sequence",
+			rows:   [][]interface{}{header(), {"org/repo", "dev", "", "v-dev-0.0.7", "bob"}},
+			rowIdx: 1,
+			want:   [][]interface{}{{"Repo", "Branch", "Tag"}, {"org/repo", "prod", ""}},
+		},
+		{
+			name:   "short existing row",
+			rows:   [][]interface{}{header(), {"org/repo"}},
+			rowIdx: 1,
+			want:   [][]interface{}{{"Repo", "Branch", "Tag"}, {"org/repo", "prod", ""}},
+		},
+		{
+			name:   "append to headerless legacy layout",
+			rows:   [][]interface{}{{"org/other", "main", "", "v-main-0.0.1", "bob"}},
+			rowIdx: -1,
+			want:   [][]interface{}{{"org/other", "main", "v-main-0.0.1"}, {"org/repo", "prod", ""}},
+		},
+		{
+			name:   "empty tab gets headers",
+			rows:   nil,
+			rowIdx: -1,
+			want:   [][]interface{}{{"Repo", "Branch", "Tag"}, {"org/repo", "prod", ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, srv := newTestService(t, tt.rows)
+			if err := s.SetMergeBranch(context.Background(), "org/repo", "prod", tt.rowIdx); err != nil {
+				t.Fatalf("SetMergeBranch() error = %v", err)
+			}
+			got := columns(srv.Values(testSheetID, testTabs.Repos), 0, 1, 3)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sheet = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateRepoTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     [][]interface{}
+		rowIdx   int
+		expected string
+		wantTag  string
+		wantErr  error
+	}{
+		{
+			name:     "updates tag",
+			rows:     [][]interface{}{header(), {"org/repo", "dev", "", "v-dev-0.0.1", "bob"}},
+			rowIdx:   1,
+			expected: "v-dev-0.0.1",
+			wantTag:  "v-dev-0.0.2",
+		},
+		{
+			name:     "first tag on short row",
+			rows:     [][]interface{}{header(), {"org/repo", "dev"}},
+			rowIdx:   1,
+			expected: "",
+			wantTag:  "v-dev-0.0.2",
+		},
+		{
+			name:     "headerless legacy layout",
+			rows:     [][]interface{}{{"org/repo", "dev", "", "v-dev-0.0.1", "bob"}},
+			rowIdx:   0,
+			expected: "v-dev-0.0.1",
+			wantTag:  "v-dev-0.0.2",
+		},
+		{
+			name:     "tag changed concurrently",
+			rows:     [][]interface{}{header(), {"org/repo", "dev", "", "v-dev-0.0.2", "bob"}},
+			rowIdx:   1,
+			expected: "v-dev-0.0.1",
+			wantTag:  "v-dev-0.0.2",
+			wantErr:  structures.ErrTagConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, srv := newTestService(t, tt.rows)
+			err := s.UpdateRepoTag(context.Background(), tt.rowIdx, tt.expected, "v-dev-0.0.2")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateRepoTag() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := srv.Values(testSheetID, testTabs.Repos)[tt.rowIdx][3]; got != tt.wantTag {
+				t.Errorf("tag cell = %v, want %v", got, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestUpdateRepoTagUsesHeaderColumns(t *testing.T) {
+	s, srv := newTestService(t, [][]interface{}{
+		{"Tag", "Repo", "User", "Branch", "Time"},
+		{"v1", "org/repo", "bob", "dev", ""},
+	})
+	if err := s.UpdateRepoTag(context.Background(), 1, "v1", "v2"); err != nil {
+		t.Fatalf("UpdateRepoTag() error = %v", err)
+	}
+	row := srv.Values(testSheetID, testTabs.Repos)[1]
+	if row[0] != "v2" || row[1] != "org/repo" || row[3] != "dev" {
+		t.Errorf("row = %v, want tag in column A and repo and branch untouched", row)
+	}
+}
+
+func TestUpdateRepoTagMissingRow(t *testing.T) {
+	s, _ := newTestService(t, [][]interface{}{header()})
+	for _, rowIdx := range []int{-1, 5} {
+		if err := s.UpdateRepoTag(context.Background(), rowIdx, "", "v1"); err == nil {
+			t.Errorf("UpdateRepoTag(rowIdx=%d) succeeded, want error", rowIdx)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	s, srv := newTestService(t, [][]interface{}{header()})
+	srv.SetValues(testSheetID, testTabs.History, nil)
+	ctx := context.Background()
+
+	records := []structures.HistoryRecord{
+		{Repo: "org/repo", MergeBranch: "dev", Tag: "v-dev-0.0.1", Outcome: structures.OutcomeSuccess},
+		{Repo: "org/other", MergeBranch: "main", Tag: "v-main-0.0.1", Outcome: structures.OutcomeSuccess},
+		{Repo: "org/repo", MergeBranch: "dev", Outcome: structures.OutcomeConflict},
+	}
+	for _, r := range records {
+		if err := s.AppendHistory(ctx, r); err != nil {
+			t.Fatalf("AppendHistory() error = %v", err)
+		}
+	}
+
+	got, err := s.ListHistory(ctx, "org/repo")
+	if err != nil {
+		t.Fatalf("ListHistory() error = %v", err)
+	}
+	want := []structures.HistoryRecord{records[0], records[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListHistory() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHistoryWithoutHeaderRow(t *testing.T) {
+	s, srv := newTestService(t, [][]interface{}{header()})
+	srv.SetValues(testSheetID, testTabs.History, [][]interface{}{{"org/repo", "dev", "v-dev-0.0.1"}})
+
+	_, err := s.ListHistory(context.Background(), "org/repo")
+	if err == nil || !strings.Contains(err.Error(), "forklift sheet setup") {
+		t.Errorf("ListHistory() error = %v, want a hint to run sheet setup", err)
+	}
+}
+
+func TestAcquireLockConcurrently(t *testing.T) {
+	s, srv := newTestService(t, [][]interface{}{header()})
+	s.lockSettleDelay = 200 * time.Millisecond
+	srv.SetValues(testSheetID, testTabs.Locks, [][]interface{}{{"Repo", "Owner", "Expires"}})
+	ctx := context.Background()
+
+	// Neither build finds a row for the repo, so both may append one
+	owners := []string{"alice", "bob"}
+	errs := make([]error, len(owners))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, owner := range owners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = s.AcquireLock(ctx, "org/repo", owner, time.Minute, false)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	var winner string
+	for i, err := range errs {
+		switch {
+		case err == nil && winner == "":
+			winner = owners[i]
+		case !errors.Is(err, structures.ErrLockHeld):
+			t.Fatalf("AcquireLock(%s) error = %v, want one success and one %v", owners[i], err, structures.ErrLockHeld)
+		}
+	}
+	if winner == "" {
+		t.Fatalf("AcquireLock() errors = %v, want one build to get the lock", errs)
+	}
+	var held []string
+	for _, row := range srv.Values(testSheetID, testTabs.Locks)[1:] {
+		if len(row) > 1 && row[1] != "" {
+			held = append(held, row[1].(string))
+		}
+	}
+	if !reflect.DeepEqual(held, []string{winner}) {
+		t.Errorf("owners in %s = %v, want only %s", testTabs.Locks, held, winner)
+	}
+
+	if err := s.ReleaseLock(ctx, "org/repo", winner); err != nil {
+		t.Fatalf("ReleaseLock() error = %v", err)
+	}
+	if _, err := s.AcquireLock(ctx, "org/repo", "carol", time.Minute, false); err != nil {
+		t.Errorf("AcquireLock() after release error = %v", err)
+	}
+}
+
+func TestColumnLetter(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 4: "E", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnLetter(i); got != want {
+			t.Errorf("columnLetter(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+// columns projects rows onto the given column indices, padding short rows with "".
+func columns(rows [][]interface{}, idx ...int) [][]interface{} {
+	var out [][]interface{}
+	for _, row := range rows {
+		var r []interface{}
+		for _, i := range idx {
+			if i < len(row) {
+				r = append(r, row[i])
+			} else {
+				r = append(r, "")
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,397 @@
+// Package sheetstest provides an in-memory fake of the parts of the Google Sheets
+// API that forklift uses, served over HTTP like net/http/httptest.
+package sheetstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is a fake Sheets API. Point a client at it with
+// option.WithEndpoint(srv.URL + "/") and option.WithHTTPClient(srv.Client()).
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	spreadsheets map[string]*spreadsheet
+}
+
+type spreadsheet struct {
+	tabs   []*tab // in creation order
+	nextID int64
+}
+
+type tab struct {
+	id    int64
+	title string
+	rows  [][]interface{}
+}
+
+// NewServer starts a fake Sheets API. Call Close when done.
+func NewServer() *Server {
+	s := &Server{spreadsheets: make(map[string]*spreadsheet)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetValues replaces the contents of a tab, creating the spreadsheet and tab as needed.
+func (s *Server) SetValues(spreadsheetID, title string, rows [][]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tab(spreadsheetID, title, true)
+	t.rows = rows
+}
+
+// Values returns a copy of the contents of a tab, or nil if it does not exist.
+func (s *Server) Values(spreadsheetID, title string) [][]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tab(spreadsheetID, title, false)
+	if t == nil {
+		return nil
+	}
+	rows := make([][]interface{}, len(t.rows))
+	for i, row := range t.rows {
+		rows[i] = append([]interface{}(nil), row...)
+	}
+	return rows
+}
+
+func (s *Server) tab(spreadsheetID, title string, create bool) *tab {
+	ss, ok := s.spreadsheets[spreadsheetID]
+	if !ok {
+		if !create {
+			return nil
+		}
+		ss = &spreadsheet{}
+		s.spreadsheets[spreadsheetID] = ss
+	}
+	for _, t := range ss.tabs {
+		if t.title == title {
+			return t
+		}
+	}
+	if !create {
+		return nil
+	}
+	t := &tab{id: ss.nextID, title: title}
+	ss.nextID++
+	ss.tabs = append(ss.tabs, t)
+	return t
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/v4/spreadsheets/")
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(path, "/values:batchUpdate") && r.Method == http.MethodPost:
+		s.batchUpdateValues(w, r, strings.TrimSuffix(path, "/values:batchUpdate"))
+	case strings.HasSuffix(path, ":batchUpdate") && r.Method == http.MethodPost:
+		s.batchUpdate(w, r, strings.TrimSuffix(path, ":batchUpdate"))
+	case strings.Contains(path, "/values/"):
+		id, rng, _ := strings.Cut(path, "/values/")
+		switch {
+		case strings.HasSuffix(rng, ":append") && r.Method == http.MethodPost:
+			s.appendValues(w, r, id, strings.TrimSuffix(rng, ":append"))
+		case r.Method == http.MethodGet:
+			s.getValues(w, id, rng)
+		case r.Method == http.MethodPut:
+			s.updateValues(w, r, id, rng)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "unsupported method %s", r.Method)
+		}
+	case !strings.ContainsAny(path, "/:") && r.Method == http.MethodGet:
+		s.getSpreadsheet(w, path)
+	default:
+		writeError(w, http.StatusNotFound, "unsupported call %s %s", r.Method, r.URL.Path)
+	}
+}
+
+type valueRange struct {
+	Range  string          `json:"range,omitempty"`
+	Values [][]interface{} `json:"values,omitempty"`
+}
+
+func (s *Server) getValues(w http.ResponseWriter, id, rng string) {
+	t, a, ok := s.resolve(w, id, rng)
+	if !ok {
+		return
+	}
+	var out [][]interface{}
+	for r := a.startRow; r < len(t.rows) && (a.endRow < 0 || r <= a.endRow); r++ {
+		var row []interface{}
+		for c := a.startCol; c < len(t.rows[r]) && (a.endCol < 0 || c <= a.endCol); c++ {
+			row = append(row, t.rows[r][c])
+		}
+		out = append(out, trimRow(row))
+	}
+	// Like the real API, trailing empty rows are omitted
+	for len(out) > 0 && len(out[len(out)-1]) == 0 {
+		out = out[:len(out)-1]
+	}
+	writeJSON(w, valueRange{Range: rng, Values: out})
+}
+
+func (s *Server) updateValues(w http.ResponseWriter, r *http.Request, id, rng string) {
+	var vr valueRange
+	if err := json.NewDecoder(r.Body).Decode(&vr); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+	t, a, ok := s.resolve(w, id, rng)
+	if !ok {
+		return
+	}
+	t.write(a.startRow, a.startCol, vr.Values)
+	writeJSON(w, map[string]interface{}{"updatedRange": rng})
+}
+
+func (s *Server) appendValues(w http.ResponseWriter, r *http.Request, id, rng string) {
+	var vr valueRange
+	if err := json.NewDecoder(r.Body).Decode(&vr); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+	t, a, ok := s.resolve(w, id, rng)
+	if !ok {
+		return
+	}
+	// Append after the last non-empty row of the table
+	last := len(t.rows) - 1
+	for last >= 0 && len(trimRow(t.rows[last])) == 0 {
+		last--
+	}
+	t.write(last+1, a.startCol, vr.Values)
+	writeJSON(w, map[string]interface{}{"tableRange": rng})
+}
+
+func (s *Server) batchUpdateValues(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Data []valueRange `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+	for _, vr := range req.Data {
+		t, a, ok := s.resolve(w, id, vr.Range)
+		if !ok {
+			return
+		}
+		t.write(a.startRow, a.startCol, vr.Values)
+	}
+	writeJSON(w, map[string]interface{}{"totalUpdatedCells": len(req.Data)})
+}
+
+type sheetProperties struct {
+	SheetID int64  `json:"sheetId"`
+	Title   string `json:"title"`
+}
+
+func (s *Server) getSpreadsheet(w http.ResponseWriter, id string) {
+	ss, ok := s.spreadsheets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "spreadsheet %s not found", id)
+		return
+	}
+	type sheet struct {
+		Properties sheetProperties `json:"properties"`
+	}
+	var sheets []sheet
+	for _, t := range ss.tabs {
+		sheets = append(sheets, sheet{Properties: sheetProperties{SheetID: t.id, Title: t.title}})
+	}
+	writeJSON(w, map[string]interface{}{"spreadsheetId": id, "sheets": sheets})
+}
+
+// batchUpdate supports adding tabs and inserting rows. Formatting requests such
+// as frozen rows and data validation are accepted and ignored.
+func (s *Server) batchUpdate(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Requests []struct {
+			AddSheet *struct {
+				Properties sheetProperties `json:"properties"`
+			} `json:"addSheet"`
+			InsertDimension *struct {
+				Range struct {
+					SheetID    int64  `json:"sheetId"`
+					Dimension  string `json:"dimension"`
+					StartIndex int    `json:"startIndex"`
+					EndIndex   int    `json:"endIndex"`
+				} `json:"range"`
+			} `json:"insertDimension"`
+		} `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+
+	var replies []interface{}
+	for _, rq := range req.Requests {
+		switch {
+		case rq.AddSheet != nil:
+			if s.tab(id, rq.AddSheet.Properties.Title, false) != nil {
+				writeError(w, http.StatusBadRequest, "a sheet with the name %q already exists", rq.AddSheet.Properties.Title)
+				return
+			}
+			t := s.tab(id, rq.AddSheet.Properties.Title, true)
+			replies = append(replies, map[string]interface{}{
+				"addSheet": map[string]interface{}{"properties": sheetProperties{SheetID: t.id, Title: t.title}},
+			})
+		case rq.InsertDimension != nil && rq.InsertDimension.Range.Dimension == "ROWS":
+			rg := rq.InsertDimension.Range
+			t := s.tabByID(id, rg.SheetID)
+			if t == nil {
+				writeError(w, http.StatusBadRequest, "no sheet with id %d", rg.SheetID)
+				return
+			}
+			if rg.StartIndex <= len(t.rows) {
+				blank := make([][]interface{}, rg.EndIndex-rg.StartIndex)
+				t.rows = append(t.rows[:rg.StartIndex], append(blank, t.rows[rg.StartIndex:]...)...)
+			}
+			replies = append(replies, map[string]interface{}{})
+		default:
+			replies = append(replies, map[string]interface{}{})
+		}
+	}
+	writeJSON(w, map[string]interface{}{"spreadsheetId": id, "replies": replies})
+}
+
+func (s *Server) tabByID(spreadsheetID string, id int64) *tab {
+	ss, ok := s.spreadsheets[spreadsheetID]
+	if !ok {
+		return nil
+	}
+	for _, t := range ss.tabs {
+		if t.id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// resolve finds the tab and cells an A1 range refers to, writing an error response if it can't.
+func (s *Server) resolve(w http.ResponseWriter, id, rng string) (*tab, a1Range, bool) {
+	title, a, err := parseA1(rng)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Unable to parse range: %s", rng)
+		return nil, a, false
+	}
+	t := s.tab(id, title, false)
+	if t == nil {
+		writeError(w, http.StatusBadRequest, "Unable to parse range: %s", rng)
+		return nil, a, false
+	}
+	return t, a, true
+}
+
+func (t *tab) write(startRow, startCol int, values [][]interface{}) {
+	for i, row := range values {
+		r := startRow + i
+		for len(t.rows) <= r {
+			t.rows = append(t.rows, nil)
+		}
+		for j, v := range row {
+			c := startCol + j
+			for len(t.rows[r]) <= c {
+				t.rows[r] = append(t.rows[r], "")
+			}
+			t.rows[r][c] = v
+		}
+	}
+}
+
+// a1Range holds zero-based, inclusive bounds; -1 means unbounded.
+type a1Range struct {
+	startRow, startCol, endRow, endCol int
+}
+
+// parseA1 parses ranges such as 'tab'!A1, tab!B2:D, tab!A:ZZ and tab!1:1.
+func parseA1(rng string) (string, a1Range, error) {
+	all := a1Range{endRow: -1, endCol: -1}
+	i := strings.LastIndex(rng, "!")
+	if i < 0 {
+		return unquote(rng), all, nil
+	}
+	title, cells := unquote(rng[:i]), rng[i+1:]
+
+	from, to, isSpan := strings.Cut(cells, ":")
+	r1, c1, err := parseCell(from)
+	if err != nil {
+		return "", all, err
+	}
+	a := a1Range{startRow: max(r1, 0), startCol: max(c1, 0), endRow: r1, endCol: c1}
+	if isSpan {
+		r2, c2, err := parseCell(to)
+		if err != nil {
+			return "", all, err
+		}
+		a.endRow, a.endCol = r2, c2
+	}
+	return title, a, nil
+}
+
+// parseCell parses "B3", "B" or "3" into zero-based row and column, -1 for a missing part.
+func parseCell(cell string) (int, int, error) {
+	col := -1
+	i := 0
+	for i < len(cell) && cell[i] >= 'A' && cell[i] <= 'Z' {
+		col = (col+1)*26 + int(cell[i]-'A')
+		i++
+	}
+	row := -1
+	if i < len(cell) {
+		n, err := strconv.Atoi(cell[i:])
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid cell %q", cell)
+		}
+		row = n - 1
+	}
+	if i == 0 && row < 0 {
+		return 0, 0, fmt.Errorf("invalid cell %q", cell)
+	}
+	return row, col, nil
+}
+
+func unquote(title string) string {
+	if len(title) >= 2 && title[0] == '\'' && title[len(title)-1] == '\'' {
+		return strings.ReplaceAll(title[1:len(title)-1], "''", "'")
+	}
+	return title
+}
+
+func trimRow(row []interface{}) []interface{} {
+	for len(row) > 0 && (row[len(row)-1] == nil || row[len(row)-1] == "") {
+		row = row[:len(row)-1]
+	}
+	return row
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": fmt.Sprintf(format, args...),
+		},
+	})
+}
@@ -1,28 +1,97 @@
 package structures
 
+import (
+	"errors"
+	"time"
+)
+
+// Errors shared by every state backend
+var (
+	ErrLockHeld    = errors.New("build lock is held by someone else")
+	ErrTagConflict = errors.New("latest tag was changed concurrently")
+)
+
 // Config holds the application configuration
 type Config struct {
-	SheetID         string `json:"sheet_id"`
-	SheetName       string `json:"sheet_name"`
-	CredentialsPath string `json:"credentials_path"`
-	GitHubToken     string `json:"github_token,omitempty"`
-	PollInterval    int    `json:"poll_interval,omitempty"` // seconds, default: 30
-	PollTimeout     int    `json:"poll_timeout,omitempty"`  // minutes, default: 30
+	Backend         string   `json:"backend,omitempty"`    // sheets (default) or file
+	StatePath       string   `json:"state_path,omitempty"` // JSON state file used by the file backend
+	SheetID         string   `json:"sheet_id"`
+	SheetName       string   `json:"sheet_name"`
+	HistorySheet    string   `json:"history_sheet,omitempty"` // default: merge_history
+	LockSheet       string   `json:"lock_sheet,omitempty"`    // default: forklift_locks
+	MetaSheet       string   `json:"meta_sheet,omitempty"`    // default: forklift_meta
+	CredentialsPath string   `json:"credentials_path"`
+	SheetsEndpoint  string   `json:"sheets_endpoint,omitempty"` // custom Sheets API base URL, e.g. a local fake
+	GitHubToken     string   `json:"github_token,omitempty"`
+	PollInterval    int      `json:"poll_interval,omitempty"`   // seconds, default: 30
+	PollTimeout     int      `json:"poll_timeout,omitempty"`    // minutes, default: 30
+	Worktree        bool     `json:"worktree,omitempty"`        // build merge in a temporary worktree by default
+	TagTemplate     string   `json:"tag_template,omitempty"`    // default: v-{branch}-{major}.{minor}.{patch}
+	TagType         string   `json:"tag_type,omitempty"`        // lightweight (default), annotated or signed
+	ReleaseNotes    string   `json:"release_notes,omitempty"`   // printed after build merge: markdown (default), json or none
+	GitHubRelease   bool     `json:"github_release,omitempty"`  // publish a GitHub release for each new tag
+	ReleaseAssets   []string `json:"release_assets,omitempty"`  // files or glob patterns attached to each release
+	ProdBranches    []string `json:"prod_branches,omitempty"`   // merge branches with full releases, default: main, master, prod, production
+	GitHubAPIURL    string   `json:"github_api_url,omitempty"`  // default: https://api.github.com
+	NotifyWebhooks  []string `json:"notify_webhooks,omitempty"` // chat webhooks told when a polled workflow finishes
 }
 
-// RepoInfo represents the repository information stored in the Google Sheet
+// RepoInfo represents the repository information stored in the state backend
 type RepoInfo struct {
 	RowIdx      int
 	MergeBranch string
 	LatestTag   string
 	LastUser    string
+
+	// Optional columns
+	Owner         string
+	Environment   string
+	Notes         string
+	TagTemplate   string // overrides the configured tag template for this repo
+	MergeStrategy string // merge (default), squash, rebase or ff-only
+}
+
+// Build outcomes recorded in HistoryRecord.Outcome
+const (
+	OutcomeSuccess  = "success"
+	OutcomeFailed   = "failed"
+	OutcomeConflict = "conflict"
+)
+
+// HistoryRecord represents a single build recorded in the tag history
+type HistoryRecord struct {
+	Repo         string `json:"repo"`
+	MergeBranch  string `json:"merge_branch"`
+	Tag          string `json:"tag"`
+	CommitSHA    string `json:"commit_sha"`
+	SourceBranch string `json:"source_branch"`
+	User         string `json:"user"`
+	Timestamp    string `json:"timestamp"` // RFC3339, UTC
+	Outcome      string `json:"outcome"`
+}
+
+// Lock represents a build lease on a repository. A lock past its expiry is stale
+// and may be taken over by anyone.
+type Lock struct {
+	Repo    string    `json:"repo"`
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
 }
 
 // BuildState represents the state of an ongoing build/merge process
 type BuildState struct {
-	OriginalBranch string `json:"original_branch"`
-	MergeBranch    string `json:"merge_branch"`
-	Stashed        bool   `json:"stashed"`
-	RepoName       string `json:"repo_name"`
-	RowIdx         int    `json:"row_idx"`
+	OriginalBranch string   `json:"original_branch"`
+	MergeBranch    string   `json:"merge_branch"`
+	Stashed        bool     `json:"stashed"`
+	RepoName       string   `json:"repo_name"`
+	RowIdx         int      `json:"row_idx"`
+	WorktreePath   string   `json:"worktree_path,omitempty"` // set when merging in a temporary worktree
+	Steps          []string `json:"steps,omitempty"`         // completed build steps, in order
+	Tag            string   `json:"tag,omitempty"`           // tag chosen for this build
+	BaseTag        string   `json:"base_tag,omitempty"`      // backend's latest tag when Tag was chosen
+	PrevTag        string   `json:"prev_tag,omitempty"`      // tag that Tag follows; release notes start here
+	Bump           string   `json:"bump,omitempty"`          // semver part to bump: major, minor or patch
+	Pre            string   `json:"pre,omitempty"`           // pre-release identifier, e.g. rc
+	Strategy       string   `json:"strategy,omitempty"`      // merge strategy: merge, squash, rebase or ff-only
+	MergeBase      string   `json:"merge_base,omitempty"`    // merge branch commit before the merge; an unpushed merge is rolled back to it
 }
@@ -0,0 +1,436 @@
+// Package tagscheme formats and parses build tags from templates such as
+// "v-{branch}-{major}.{minor}.{patch}" or "release-{date}.{seq}".
+package tagscheme
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default is the template used when none is configured. It produces the
+// v-<branch>-0.0.1 style tags forklift has always created.
+const Default = "v-{branch}-{major}.{minor}.{patch}"
+
+// DefaultDateLayout is the Go time layout of {date} when the template gives none.
+const DefaultDateLayout = "2006.01.02"
+
+// Tokens.
+const (
+	TokenBranch = "branch"
+	TokenMajor  = "major"
+	TokenMinor  = "minor"
+	TokenPatch  = "patch"
+	TokenDate   = "date"
+	TokenSHA    = "sha"
+	TokenSeq    = "seq"
+)
+
+// Bump levels.
+const (
+	Major = "major"
+	Minor = "minor"
+	Patch = "patch"
+)
+
+// ShortSHALength is the number of commit hash characters {sha} expands to.
+const ShortSHALength = 7
+
+// Version holds the values of the tokens in a tag.
+type Version struct {
+	Branch string
+	Major  int
+	Minor  int
+	Patch  int
+	Pre    string // pre-release identifier, e.g. rc; empty for a release
+	PreNum int    // pre-release number, the 2 in -rc.2
+	Date   string // formatted with the template's date layout
+	SHA    string
+	Seq    int
+}
+
+// Bump selects how Next moves from one version to the next. The zero value
+// bumps the smallest counter in the template.
+type Bump struct {
+	Level string // Major, Minor, Patch or empty
+	Pre   string // pre-release identifier to produce, e.g. rc or beta
+}
+
+// Build describes the build a new tag is made for.
+type Build struct {
+	Branch string
+	SHA    string
+	Time   time.Time
+}
+
+// Template is a parsed tag template.
+type Template struct {
+	raw    string
+	parts  []part
+	tokens map[string]bool
+	layout string
+	re     *regexp.Regexp
+	// semverEnd is the index of the part after which a pre-release suffix
+	// such as -rc.1 goes: the last semver token. -1 if there is none.
+	semverEnd int
+}
+
+// part is a literal or a token of a template.
+type part struct {
+	literal string
+	token   string
+}
+
+var (
+	tokenPattern = regexp.MustCompile(`\{([a-z]+)(?::([^}]*))?\}`)
+	prePattern   = regexp.MustCompile(`^[0-9A-Za-z]+$`)
+)
+
+// Parse parses a template. Tokens are written in braces: {branch}, {major},
+// {minor}, {patch}, {date}, {sha} and {seq}. {date} takes an optional Go time
+// layout, e.g. {date:20060102}. A template needs at least one counter
+// ({major}, {minor}, {patch} or {seq}) so that consecutive tags differ.
+// Pre-release suffixes such as -rc.1 follow the last semver token.
+func Parse(tmpl string) (*Template, error) {
+	t := &Template{raw: tmpl, tokens: make(map[string]bool), layout: DefaultDateLayout, semverEnd: -1}
+	matches := tokenPattern.FindAllStringSubmatchIndex(tmpl, -1)
+	lastSemver := -1
+	for i, m := range matches {
+		switch tmpl[m[2]:m[3]] {
+		case TokenMajor, TokenMinor, TokenPatch:
+			lastSemver = i
+		}
+	}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+
+	last := 0
+	for i, m := range matches {
+		if m[0] > last {
+			t.addLiteral(tmpl[last:m[0]], &pattern)
+		}
+		last = m[1]
+
+		token := tmpl[m[2]:m[3]]
+		if t.tokens[token] {
+			return nil, fmt.Errorf("tag template %q uses {%s} more than once", tmpl, token)
+		}
+		t.tokens[token] = true
+		t.parts = append(t.parts, part{token: token})
+
+		if m[4] >= 0 {
+			if token != TokenDate {
+				return nil, fmt.Errorf("tag template %q: only {date} takes a layout", tmpl)
+			}
+			if t.layout = tmpl[m[4]:m[5]]; t.layout == "" {
+				return nil, fmt.Errorf("tag template %q: empty {date} layout", tmpl)
+			}
+		}
+
+		switch token {
+		case TokenBranch, TokenDate:
+			pattern.WriteString("(.+?)")
+		case TokenMajor, TokenMinor, TokenPatch, TokenSeq:
+			pattern.WriteString(`(\d+)`)
+		case TokenSHA:
+			pattern.WriteString("([0-9a-f]{4,40})")
+		default:
+			return nil, fmt.Errorf("tag template %q: unknown token {%s}", tmpl, token)
+		}
+		if i == lastSemver {
+			t.semverEnd = len(t.parts) - 1
+			pattern.WriteString(`(?:-([0-9A-Za-z]+)\.(\d+))?`)
+		}
+	}
+	if last < len(tmpl) {
+		t.addLiteral(tmpl[last:], &pattern)
+	}
+	if strings.ContainsAny(strings.Join(t.literals(), ""), "{}") {
+		return nil, fmt.Errorf("tag template %q: stray brace or malformed token", tmpl)
+	}
+	if !t.tokens[TokenMajor] && !t.tokens[TokenMinor] && !t.tokens[TokenPatch] && !t.tokens[TokenSeq] {
+		return nil, fmt.Errorf("tag template %q needs a counter: {major}, {minor}, {patch} or {seq}", tmpl)
+	}
+
+	pattern.WriteString("$")
+	t.re = regexp.MustCompile(pattern.String())
+	return t, nil
+}
+
+func (t *Template) addLiteral(s string, pattern *strings.Builder) {
+	t.parts = append(t.parts, part{literal: s})
+	pattern.WriteString(regexp.QuoteMeta(s))
+}
+
+func (t *Template) literals() []string {
+	var out []string
+	for _, p := range t.parts {
+		if p.token == "" {
+			out = append(out, p.literal)
+		}
+	}
+	return out
+}
+
+// String returns the template as written.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Has reports whether the template uses token.
+func (t *Template) Has(token string) bool {
+	return t.tokens[token]
+}
+
+// Format renders v as a tag.
+func (t *Template) Format(v Version) string {
+	var b strings.Builder
+	for i, p := range t.parts {
+		switch p.token {
+		case "":
+			b.WriteString(p.literal)
+		case TokenBranch:
+			b.WriteString(v.Branch)
+		case TokenMajor:
+			b.WriteString(strconv.Itoa(v.Major))
+		case TokenMinor:
+			b.WriteString(strconv.Itoa(v.Minor))
+		case TokenPatch:
+			b.WriteString(strconv.Itoa(v.Patch))
+		case TokenDate:
+			b.WriteString(v.Date)
+		case TokenSHA:
+			b.WriteString(v.SHA)
+		case TokenSeq:
+			b.WriteString(strconv.Itoa(v.Seq))
+		}
+		if i == t.semverEnd && v.Pre != "" {
+			fmt.Fprintf(&b, "-%s.%d", v.Pre, v.PreNum)
+		}
+	}
+	return b.String()
+}
+
+// Match parses tag back into its token values. It reports false if tag was
+// not produced by this template.
+func (t *Template) Match(tag string) (Version, bool) {
+	var v Version
+	m := t.re.FindStringSubmatch(tag)
+	if m == nil {
+		return v, false
+	}
+	i := 1
+	for j, p := range t.parts {
+		if p.token == "" {
+			continue
+		}
+		value := m[i]
+		i++
+		if j == t.semverEnd && m[i] != "" {
+			v.Pre = m[i]
+			v.PreNum, _ = strconv.Atoi(m[i+1])
+		}
+		if j == t.semverEnd {
+			i += 2
+		}
+		var err error
+		switch p.token {
+		case TokenBranch:
+			v.Branch = value
+		case TokenMajor:
+			v.Major, err = strconv.Atoi(value)
+		case TokenMinor:
+			v.Minor, err = strconv.Atoi(value)
+		case TokenPatch:
+			v.Patch, err = strconv.Atoi(value)
+		case TokenDate:
+			v.Date = value
+			_, err = time.Parse(t.layout, value)
+		case TokenSHA:
+			v.SHA = value
+		case TokenSeq:
+			v.Seq, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return v, false
+		}
+	}
+	return v, true
+}
+
+// CheckBump reports whether bump can be applied to tags of this template.
+func (t *Template) CheckBump(bump Bump) error {
+	switch bump.Level {
+	case "":
+	case Major, Minor, Patch:
+		if !t.Has(bump.Level) {
+			return fmt.Errorf("tag template %s has no {%s} to bump", t, bump.Level)
+		}
+	default:
+		return fmt.Errorf("unknown bump level %q, want %s, %s or %s", bump.Level, Major, Minor, Patch)
+	}
+	if bump.Pre != "" {
+		if !prePattern.MatchString(bump.Pre) {
+			return fmt.Errorf("invalid pre-release identifier %q, use letters and digits only", bump.Pre)
+		}
+		if t.semverEnd < 0 {
+			return fmt.Errorf("tag template %s has no semver part to attach a pre-release to", t)
+		}
+	}
+	return nil
+}
+
+// Next returns the tag that follows last for build b.
+//
+// Without a bump level, the sequence number counts up while the date stays
+// the same and restarts at 1 on a new day; without a sequence number the
+// smallest semver part present is bumped. An explicit level bumps that part,
+// resets the smaller ones and restarts the sequence. Bumps follow semver
+// precedence: a pre-release is released by bumping the part it was made for,
+// so 1.0.0-rc.2 becomes 1.0.0, and --pre rc turns 1.0.0-rc.2 into 1.0.0-rc.3.
+//
+// If last was not produced by this template for b's branch, for example
+// because the template changed, a new sequence is started from 0.0.0.
+func (t *Template) Next(last string, b Build, bump Bump) (string, error) {
+	if err := t.CheckBump(bump); err != nil {
+		return "", err
+	}
+	prev, ok := t.Match(last)
+	if !ok || (t.Has(TokenBranch) && prev.Branch != b.Branch) {
+		prev = Version{}
+	}
+
+	v := t.stamp(prev, b)
+	level := bump.Level
+	switch {
+	case level != "":
+		v.Seq = 1
+	case t.Has(TokenSeq) && t.Has(TokenDate) && v.Date != prev.Date:
+		v.Seq = 1
+	case t.Has(TokenSeq) && bump.Pre == "":
+		v.Seq++
+	case t.Has(TokenPatch):
+		level = Patch
+	case t.Has(TokenMinor):
+		level = Minor
+	default:
+		level = Major
+	}
+
+	switch {
+	case bump.Pre != "" && bump.Level == "" && prev.Pre != "":
+		// Next pre-release of the same version
+		if prev.Pre == bump.Pre {
+			v.PreNum++
+		} else {
+			v.Pre, v.PreNum = bump.Pre, 1
+		}
+	case bump.Pre != "":
+		v = bumpLevel(v, level, false)
+		v.Pre, v.PreNum = bump.Pre, 1
+	case level != "":
+		v = bumpLevel(v, level, prev.Pre != "")
+	}
+	return t.Format(v), nil
+}
+
+// bumpLevel bumps the given semver part and resets the smaller ones. When
+// releasing a pre-release whose smaller parts are already zero, the version
+// is kept as is: 1.0.0-rc.2 bumped to a release is 1.0.0.
+func bumpLevel(v Version, level string, release bool) Version {
+	v.Pre, v.PreNum = "", 0
+	switch level {
+	case Major:
+		if !release || v.Minor != 0 || v.Patch != 0 {
+			v.Major++
+		}
+		v.Minor, v.Patch = 0, 0
+	case Minor:
+		if !release || v.Patch != 0 {
+			v.Minor++
+		}
+		v.Patch = 0
+	case Patch:
+		if !release {
+			v.Patch++
+		}
+	}
+	return v
+}
+
+// Compare orders two versions of this template by date, then semver (a
+// pre-release sorts before its release), then sequence number. It returns
+// -1, 0 or +1.
+func (t *Template) Compare(a, b Version) int {
+	if t.Has(TokenDate) && a.Date != b.Date {
+		da, _ := time.Parse(t.layout, a.Date)
+		db, _ := time.Parse(t.layout, b.Date)
+		if c := da.Compare(db); c != 0 {
+			return c
+		}
+	}
+	if c := cmp.Compare(a.Major, b.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Minor, b.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Patch, b.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case a.Pre == "" && b.Pre != "":
+		return 1
+	case a.Pre != "" && b.Pre == "":
+		return -1
+	}
+	if c := cmp.Compare(a.Pre, b.Pre); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.PreNum, b.PreNum); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Seq, b.Seq)
+}
+
+// Highest returns the highest of tags that this template produced for branch,
+// or "" if none did.
+func (t *Template) Highest(tags []string, branch string) string {
+	var best string
+	var bestVersion Version
+	for _, tag := range tags {
+		v, ok := t.Match(tag)
+		if !ok || (t.Has(TokenBranch) && v.Branch != branch) {
+			continue
+		}
+		if best == "" || t.Compare(v, bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+	return best
+}
+
+// Newer reports whether tag is a higher tag of this template than base. Any
+// matching tag is newer than a base the template did not produce.
+func (t *Template) Newer(tag, base string) bool {
+	v, ok := t.Match(tag)
+	if !ok {
+		return false
+	}
+	bv, ok := t.Match(base)
+	return !ok || t.Compare(v, bv) > 0
+}
+
+// stamp fills in the tokens that come from the build rather than the previous tag.
+func (t *Template) stamp(v Version, b Build) Version {
+	v.Branch = b.Branch
+	v.Date = b.Time.UTC().Format(t.layout)
+	v.SHA = b.SHA
+	if len(v.SHA) > ShortSHALength {
+		v.SHA = v.SHA[:ShortSHALength]
+	}
+	return v
+}
@@ -0,0 +1,157 @@
+package tagscheme
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	oct17 := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	oct18 := oct17.AddDate(0, 0, 1)
+	sha := "3f9c2a1b7d4e5f60718293a4b5c6d7e8f9012345"
+
+	tests := []struct {
+		name     string
+		template string
+		last     string
+		build    Build
+		want     string
+	}{
+		{"default first tag", Default, "", Build{Branch: "dev"}, "v-dev-0.0.1"},
+		{"default bumps patch", Default, "v-dev-0.0.9", Build{Branch: "dev"}, "v-dev-0.0.10"},
+		{"default keeps major and minor", Default, "v-dev-1.4.2", Build{Branch: "dev"}, "v-dev-1.4.3"},
+		{"branch with dashes", Default, "v-feature-x-0.0.3", Build{Branch: "feature-x"}, "v-feature-x-0.0.4"},
+		{"other branch starts over", Default, "v-dev-0.0.3", Build{Branch: "staging"}, "v-staging-0.0.1"},
+		{"foreign tag starts over", Default, "build-42", Build{Branch: "dev"}, "v-dev-0.0.1"},
+		{"calver same day", "release-{date}.{seq}", "release-2026.10.17.3", Build{Branch: "prod", Time: oct17}, "release-2026.10.17.4"},
+		{"calver new day", "release-{date}.{seq}", "release-2026.10.17.3", Build{Branch: "prod", Time: oct18}, "release-2026.10.18.1"},
+		{"calver first tag", "release-{date}.{seq}", "", Build{Branch: "prod", Time: oct17}, "release-2026.10.17.1"},
+		{"date layout", "{branch}/{date:20060102}-{seq}", "prod/20261017-2", Build{Branch: "prod", Time: oct17}, "prod/20261017-3"},
+		{"build number", "build-{seq}", "build-41", Build{Branch: "dev"}, "build-42"},
+		{"short sha", "v{major}.{minor}.{patch}+{sha}", "v1.2.3+0a1b2c3", Build{Branch: "dev", SHA: sha}, "v1.2.4+3f9c2a1"},
+		{"minor only", "v{major}.{minor}", "v2.7", Build{Branch: "dev"}, "v2.8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.template)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.template, err)
+			}
+			if got, err := tmpl.Next(tt.last, tt.build, Bump{}); err != nil || got != tt.want {
+				t.Errorf("Next(%q) = %q, %v; want %q", tt.last, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextBump(t *testing.T) {
+	dev := Build{Branch: "dev"}
+	tests := []struct {
+		last string
+		bump Bump
+		want string
+	}{
+		{"v-dev-0.3.9", Bump{Level: Minor}, "v-dev-0.4.0"},
+		{"v-dev-0.3.9", Bump{Level: Major}, "v-dev-1.0.0"},
+		{"v-dev-0.3.9", Bump{Level: Patch}, "v-dev-0.3.10"},
+		{"", Bump{Level: Minor}, "v-dev-0.1.0"},
+		{"v-dev-0.3.9", Bump{Pre: "rc"}, "v-dev-0.3.10-rc.1"},
+		{"v-dev-0.3.9", Bump{Level: Major, Pre: "rc"}, "v-dev-1.0.0-rc.1"},
+		{"v-dev-1.0.0-rc.2", Bump{Pre: "rc"}, "v-dev-1.0.0-rc.3"},
+		{"v-dev-1.0.0-beta.4", Bump{Pre: "rc"}, "v-dev-1.0.0-rc.1"},
+		{"v-dev-1.0.0-rc.2", Bump{}, "v-dev-1.0.0"},
+		{"v-dev-1.0.0-rc.2", Bump{Level: Major}, "v-dev-1.0.0"},
+		{"v-dev-1.0.1-rc.2", Bump{Level: Major}, "v-dev-2.0.0"},
+		{"v-dev-1.2.0-rc.1", Bump{Level: Minor}, "v-dev-1.2.0"},
+		{"v-dev-1.0.0-rc.2", Bump{Level: Minor, Pre: "rc"}, "v-dev-1.1.0-rc.1"},
+	}
+	tmpl, _ := Parse(Default)
+	for _, tt := range tests {
+		got, err := tmpl.Next(tt.last, dev, tt.bump)
+		if err != nil || got != tt.want {
+			t.Errorf("Next(%q, %+v) = %q, %v; want %q", tt.last, tt.bump, got, err, tt.want)
+		}
+	}
+
+	// Pre-releases go right after the semver parts
+	tmpl, _ = Parse("v{major}.{minor}.{patch}+{sha}")
+	got, err := tmpl.Next("v1.2.3+0a1b2c3", Build{SHA: "3f9c2a1"}, Bump{Level: Minor, Pre: "beta"})
+	if want := "v1.3.0-beta.1+3f9c2a1"; err != nil || got != want {
+		t.Errorf("Next() = %q, %v; want %q", got, err, want)
+	}
+
+	calver, _ := Parse("release-{date}.{seq}")
+	for _, bump := range []Bump{{Level: Minor}, {Pre: "rc"}} {
+		if _, err := calver.Next("", dev, bump); err == nil {
+			t.Errorf("calver Next(%+v) succeeded, want error", bump)
+		}
+	}
+	if _, err := tmpl.Next("", dev, Bump{Level: "huge"}); err == nil {
+		t.Error("Next() with unknown level succeeded, want error")
+	}
+	if _, err := tmpl.Next("", dev, Bump{Pre: "rc-1"}); err == nil {
+		t.Error("Next() with invalid pre-release succeeded, want error")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		template string
+		version  Version
+	}{
+		{Default, Version{Branch: "release/1.x", Major: 1, Minor: 0, Patch: 12}},
+		{Default, Version{Branch: "dev", Major: 2, Pre: "rc", PreNum: 3}},
+		{"release-{date}.{seq}", Version{Date: "2026.10.17", Seq: 3}},
+		{"{branch}-{date:2006-01-02}-{sha}-{seq}", Version{Branch: "qa", Date: "2026-10-17", SHA: "3f9c2a1", Seq: 1}},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.template, err)
+		}
+		tag := tmpl.Format(tt.version)
+		got, ok := tmpl.Match(tag)
+		if !ok || got != tt.version {
+			t.Errorf("%s: Match(Format(%+v)) = %+v, %v", tt.template, tt.version, got, ok)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tmpl := range []string{
+		"v-{branch}",                 // no counter
+		"v-{branch}-{patch}-{patch}", // duplicate
+		"v-{build}.{seq}",            // unknown token
+		"v-{Branch}.{seq}",           // not a token
+		"v-{seq:2006}",               // layout on non-date
+		"v-{seq",                     // unterminated
+	} {
+		if _, err := Parse(tmpl); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", tmpl)
+		}
+	}
+}
+
+func TestHighest(t *testing.T) {
+	tests := []struct {
+		template string
+		tags     []string
+		branch   string
+		want     string
+	}{
+		{Default, []string{"v-dev-0.0.9", "v-dev-0.0.10", "v-dev-0.0.2", "v-prod-0.1.0", "build-99"}, "dev", "v-dev-0.0.10"},
+		{Default, []string{"v-dev-1.0.0-rc.2", "v-dev-1.0.0", "v-dev-1.0.0-rc.10"}, "dev", "v-dev-1.0.0"},
+		{Default, []string{"v-dev-1.0.0-rc.2", "v-dev-1.0.0-rc.10", "v-dev-1.0.0-beta.7"}, "dev", "v-dev-1.0.0-rc.10"},
+		{"release-{date}.{seq}", []string{"release-2026.10.17.9", "release-2026.10.18.1", "release-2026.09.30.12"}, "prod", "release-2026.10.18.1"},
+		{Default, []string{"v-staging-0.0.3"}, "dev", ""},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tmpl.Highest(tt.tags, tt.branch); got != tt.want {
+			t.Errorf("Highest(%v) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}